@@ -0,0 +1,12 @@
+data "git_branch_name" "example" {
+  template = "tf/{workspace}/{name}"
+  vars = {
+    workspace = terraform.workspace
+    name      = "Update config"
+  }
+  sanitize = true
+}
+
+output "branch_name" {
+  value = data.git_branch_name.example.name
+}
\ No newline at end of file
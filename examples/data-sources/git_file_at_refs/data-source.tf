@@ -0,0 +1,9 @@
+data "git_file_at_refs" "example" {
+  url  = "https://example.com/repo-name"
+  path = "VERSION"
+  refs = ["release-1.0", "release-1.1", "main"]
+}
+
+output "versions" {
+  value = data.git_file_at_refs.example.contents
+}
\ No newline at end of file
@@ -0,0 +1,13 @@
+data "git_merge_check" "example" {
+  url  = "https://example.com/repo-name"
+  base = "production"
+  head = "staging"
+}
+
+output "can_promote" {
+  value = data.git_merge_check.example.clean
+}
+
+output "conflicts" {
+  value = data.git_merge_check.example.conflicts
+}
\ No newline at end of file
@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// hostCredentials are the HTTP(S) credentials of a single host.
+type hostCredentials struct {
+	host string
+	auth transport.AuthMethod
+}
+
+// authFor returns the auth method for a repository URL. SSH URLs use the SSH
+// auth, while HTTP(S) URLs use the credentials of their host if configured,
+// the CodeCommit and Cloud Source Repositories auth for those hosts, and the
+// provider wide credentials otherwise.
+func (c *providerConfig) authFor(rawURL string) transport.AuthMethod {
+	ep, err := transport.NewEndpoint(rawURL)
+	if err != nil {
+		return c.auth
+	}
+
+	if ep.Protocol == "ssh" {
+		return c.sshAuth
+	}
+	for _, credentials := range c.hostCredentials {
+		if strings.EqualFold(credentials.host, ep.Host) {
+			return credentials.auth
+		}
+	}
+	if c.codecommitAuth != nil && isCodeCommitHost(ep.Host) {
+		return c.codecommitAuth
+	}
+	if c.googleAuth != nil && ep.Host == googleSourceHost {
+		return c.googleAuth
+	}
+
+	return c.auth
+}
@@ -0,0 +1,198 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// authBlockSchema returns the schema of the auth block, which groups the
+// credentials by kind so new kinds can be added without more top-level
+// arguments.
+func authBlockSchema() *schema.Schema {
+	tokenSchema := map[string]*schema.Schema{
+		"value": {
+			Description: "The token, sent as the password of basic auth.",
+			Type:        schema.TypeString,
+			Optional:    true,
+			Sensitive:   true,
+		},
+		"username": {
+			Description: "The username sent with the token. Most servers accept any username. Defaults to `anyuser`.",
+			Type:        schema.TypeString,
+			Optional:    true,
+			Default:     "anyuser",
+		},
+		"scheme": authSchemeSchema(),
+	}
+	addSecretFiles(tokenSchema, "auth.0.token.0.", "value")
+
+	basicSchema := map[string]*schema.Schema{
+		"username": {
+			Description: "The username.",
+			Type:        schema.TypeString,
+			Required:    true,
+		},
+		"password": {
+			Description: "The password.",
+			Type:        schema.TypeString,
+			Optional:    true,
+			Sensitive:   true,
+		},
+	}
+	addSecretFiles(basicSchema, "auth.0.basic.0.", "password")
+
+	sshSchema := map[string]*schema.Schema{
+		"private_key": {
+			Description: "The PEM encoded private key. Defaults to the keys of the running SSH agent.",
+			Type:        schema.TypeString,
+			Optional:    true,
+			Sensitive:   true,
+		},
+		"passphrase": {
+			Description: "The passphrase of `private_key`, if it is encrypted.",
+			Type:        schema.TypeString,
+			Optional:    true,
+			Sensitive:   true,
+		},
+	}
+	addSecretFiles(sshSchema, "auth.0.ssh.0.", "private_key", "passphrase")
+
+	return &schema.Schema{
+		Description: "The credentials used to authenticate to git servers. Replaces `github_token`, `token`, `username`, `password`, `ssh_private_key` and `github_app`, which conflict with the matching block. Only one HTTP(S) auth method can be set: `token`, `basic` or `github_app` here, or one of the top-level `github_token`, `token`, `password`, `gitlab_token`, `bitbucket_app_password`, `bitbucket_access_token`, `azure_devops_pat`, `oauth2`, `github_app`, `github_actions_oidc`, `credential_helper` and `credential_store`.",
+		Type:        schema.TypeList,
+		Optional:    true,
+		MaxItems:    1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"token": {
+					Description:   "Authenticate over HTTP(S) with a token, like `token`.",
+					Type:          schema.TypeList,
+					Optional:      true,
+					MaxItems:      1,
+					ConflictsWith: []string{"github_token", "github_token_file", "token", "token_file"},
+					Elem: &schema.Resource{
+						Schema: tokenSchema,
+					},
+				},
+				"basic": {
+					Description:   "Authenticate over HTTP(S) with a username and password, like `username` and `password`.",
+					Type:          schema.TypeList,
+					Optional:      true,
+					MaxItems:      1,
+					ConflictsWith: []string{"username", "password", "password_file"},
+					Elem: &schema.Resource{
+						Schema: basicSchema,
+					},
+				},
+				"ssh": {
+					Description:   "Authenticate over SSH with a private key, like `ssh_private_key`.",
+					Type:          schema.TypeList,
+					Optional:      true,
+					MaxItems:      1,
+					ConflictsWith: []string{"ssh_private_key", "ssh_private_key_file", "ssh_private_key_passphrase", "ssh_private_key_passphrase_file"},
+					Elem: &schema.Resource{
+						Schema: sshSchema,
+					},
+				},
+				"github_app": githubAppSchema("Authenticate over HTTP(S) as a GitHub App installation, like the top-level `github_app` block.", "auth.0.github_app.0.", []string{"github_app"}),
+			},
+		},
+	}
+}
+
+// applyAuthBlock sets the credentials of the auth block in values, and the
+// files they are read from in files, keyed by the top-level arguments they
+// replace. It returns the github_app block to use and whether SSH is
+// configured.
+func applyAuthBlock(block map[string]interface{}, values, files map[string]string, appItems []interface{}) ([]interface{}, bool, error) {
+	if items := block["token"].([]interface{}); len(items) > 0 && items[0] != nil {
+		item := items[0].(map[string]interface{})
+		value, err := itemSecretValue(item, "value")
+		if err != nil {
+			return nil, false, fmt.Errorf("auth token: %w", err)
+		}
+		if value == "" {
+			return nil, false, fmt.Errorf("auth token: value or value_file must be set")
+		}
+		values["token"], values["token_username"] = value, item["username"].(string)
+		values["token_auth_scheme"] = item["scheme"].(string)
+		files["token"] = item["value_file"].(string)
+	}
+
+	if items := block["basic"].([]interface{}); len(items) > 0 && items[0] != nil {
+		item := items[0].(map[string]interface{})
+		password, err := itemSecretValue(item, "password")
+		if err != nil {
+			return nil, false, fmt.Errorf("auth basic: %w", err)
+		}
+		if password == "" {
+			return nil, false, fmt.Errorf("auth basic: password or password_file must be set")
+		}
+		values["username"], values["password"] = item["username"].(string), password
+		files["password"] = item["password_file"].(string)
+	}
+
+	useSSH := false
+	if items := block["ssh"].([]interface{}); len(items) > 0 {
+		// An empty block uses the SSH agent
+		item := map[string]interface{}{"private_key": "", "private_key_file": "", "passphrase": "", "passphrase_file": ""}
+		if items[0] != nil {
+			item = items[0].(map[string]interface{})
+		}
+
+		privateKey, err := itemSecretValue(item, "private_key")
+		if err != nil {
+			return nil, false, fmt.Errorf("auth ssh: %w", err)
+		}
+		passphrase, err := itemSecretValue(item, "passphrase")
+		if err != nil {
+			return nil, false, fmt.Errorf("auth ssh: %w", err)
+		}
+		values["ssh_private_key"], values["ssh_private_key_passphrase"] = privateKey, passphrase
+		useSSH = true
+	}
+
+	if items := block["github_app"].([]interface{}); len(items) > 0 {
+		appItems = items
+	}
+
+	return appItems, useSSH, nil
+}
+
+// checkAuthMethods returns an error if more than one of the provider wide
+// HTTP(S) auth methods is configured, as only one of them can be used.
+// values holds the credentials after applyAuthBlock, so the auth block and
+// the _file variants count like the arguments they replace.
+func checkAuthMethods(d *schema.ResourceData, values map[string]string, appItems []interface{}) error {
+	methods := []struct {
+		name string
+		set  bool
+	}{
+		{"github_token", values["github_token"] != ""},
+		{"token", values["token"] != ""},
+		{"password", values["password"] != ""},
+		{"gitlab_token", values["gitlab_token"] != ""},
+		{"bitbucket_app_password", values["bitbucket_app_password"] != ""},
+		{"bitbucket_access_token", values["bitbucket_access_token"] != ""},
+		{"azure_devops_pat", values["azure_devops_pat"] != ""},
+		{"oauth2", len(d.Get("oauth2").([]interface{})) > 0},
+		{"github_app", len(appItems) > 0},
+		{"github_actions_oidc", len(d.Get("github_actions_oidc").([]interface{})) > 0},
+		{"credential_helper", d.Get("credential_helper").(string) != ""},
+		{"credential_store", d.Get("credential_store").(bool)},
+	}
+
+	var set []string
+	for _, method := range methods {
+		if method.set {
+			set = append(set, method.name)
+		}
+	}
+	if len(set) > 1 {
+		return fmt.Errorf("only one auth method can be set, got %s", strings.Join(set, ", "))
+	}
+
+	return nil
+}
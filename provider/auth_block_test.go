@@ -0,0 +1,80 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestCheckAuthMethods(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "none",
+			raw:  map[string]interface{}{},
+		},
+		{
+			name: "token",
+			raw:  map[string]interface{}{"token": "secret"},
+		},
+		{
+			name: "auth block token",
+			raw: map[string]interface{}{
+				"auth": []interface{}{map[string]interface{}{
+					"token": []interface{}{map[string]interface{}{"value": "secret"}},
+				}},
+			},
+		},
+		{
+			name:    "token and gitlab_token",
+			raw:     map[string]interface{}{"token": "secret", "gitlab_token": "secret"},
+			wantErr: true,
+		},
+		{
+			name: "auth block basic and oauth2",
+			raw: map[string]interface{}{
+				"auth": []interface{}{map[string]interface{}{
+					"basic": []interface{}{map[string]interface{}{"username": "user", "password": "secret"}},
+				}},
+				"oauth2": []interface{}{map[string]interface{}{
+					"token_url":     "https://example.com/token",
+					"client_id":     "id",
+					"client_secret": "secret",
+				}},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "credential_helper and azure_devops_pat",
+			raw:     map[string]interface{}{"credential_helper": "store", "azure_devops_pat": "secret"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, Provider().Schema, tt.raw)
+
+			values := make(map[string]string)
+			files := make(map[string]string)
+			for _, key := range secretArguments {
+				values[key] = d.Get(key).(string)
+			}
+			appItems := d.Get("github_app").([]interface{})
+			if items := d.Get("auth").([]interface{}); len(items) > 0 && items[0] != nil {
+				var err error
+				if appItems, _, err = applyAuthBlock(items[0].(map[string]interface{}), values, files, appItems); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := checkAuthMethods(d, values, appItems)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkAuthMethods() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// The auth methods of an auth_chain.
+const (
+	chainSSHAgent = "ssh_agent"
+	chainSSHKey   = "ssh_key"
+	chainHTTP     = "http"
+)
+
+// chainedAuth is an auth method of an auth_chain. SSH methods access
+// repositories over SSH and the HTTP method over HTTPS, whatever the scheme of
+// the configured URL.
+type chainedAuth struct {
+	name string
+	ssh  bool
+	// auth is nil for the HTTP method, which uses the credentials of the host
+	auth transport.AuthMethod
+	// err is why the method is unavailable, e.g. no SSH agent is running
+	err error
+}
+
+// resolvedRemote is the URL and auth method a repository was accessed with.
+type resolvedRemote struct {
+	url  string
+	auth transport.AuthMethod
+}
+
+// authChain holds the auth methods tried in order for each repository, and
+// the method that worked for the repositories accessed so far.
+type authChain struct {
+	methods []chainedAuth
+
+	mu       sync.Mutex
+	resolved map[string]resolvedRemote
+}
+
+// newAuthChain returns the chain of the named auth methods. sshKey is the auth
+// method of the configured SSH private key, if any, and sshAgent returns the
+// one of the SSH agent.
+func newAuthChain(names []string, sshKey transport.AuthMethod, sshAgent func() (transport.AuthMethod, error)) (*authChain, error) {
+	chain := &authChain{}
+	for _, name := range names {
+		method := chainedAuth{name: name}
+		switch name {
+		case chainSSHAgent:
+			method.ssh = true
+			method.auth, method.err = sshAgent()
+		case chainSSHKey:
+			if sshKey == nil {
+				return nil, fmt.Errorf("auth_chain contains %s, but no SSH private key is set", chainSSHKey)
+			}
+			method.ssh = true
+			method.auth = sshKey
+		case chainHTTP:
+		default:
+			return nil, fmt.Errorf("unknown auth_chain method %s", name)
+		}
+		chain.methods = append(chain.methods, method)
+	}
+
+	return chain, nil
+}
+
+// resolveRemote returns the URL and auth method to access the repository at
+// rawURL, which must already be rewritten. Without an auth_chain these are the
+// URL itself and its auth method. Otherwise the methods of the chain are tried
+// in order by listing the refs of the repository, and the first that works is
+// used for the lifetime of the provider.
+func (c *providerConfig) resolveRemote(ctx context.Context, rawURL string) (string, transport.AuthMethod, error) {
+	if c.authChain == nil || isLocalPath(rawURL) || strings.HasPrefix(rawURL, "file://") {
+		return rawURL, c.authFor(rawURL), nil
+	}
+
+	chain := c.authChain
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+
+	if remote, ok := chain.resolved[rawURL]; ok {
+		return remote.url, remote.auth, nil
+	}
+
+	var failures []string
+	for _, method := range chain.methods {
+		if method.err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", method.name, method.err))
+			continue
+		}
+
+		candidate, err := chainURL(rawURL, method.ssh)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", method.name, err))
+			continue
+		}
+		auth := method.auth
+		if auth == nil {
+			auth = c.authFor(candidate)
+		}
+
+		if err := listRemote(ctx, candidate, gogit.DefaultRemoteName, auth); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", method.name, err))
+			continue
+		}
+
+		tflog.Info(ctx, "authenticated to git repository", map[string]interface{}{
+			"url":         redact(candidate, c),
+			"auth_method": method.name,
+		})
+
+		remote := resolvedRemote{url: candidate, auth: auth}
+		if chain.resolved == nil {
+			chain.resolved = make(map[string]resolvedRemote)
+		}
+		chain.resolved[rawURL] = remote
+		chain.resolved[candidate] = remote
+		return remote.url, remote.auth, nil
+	}
+
+	return "", nil, fmt.Errorf("no method of auth_chain can access %s: %s", rawURL, strings.Join(failures, "; "))
+}
+
+// chainURL returns the SSH or HTTPS URL of the repository at rawURL.
+func chainURL(rawURL string, ssh bool) (string, error) {
+	ep, err := transport.NewEndpoint(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	path := "/" + strings.TrimPrefix(ep.Path, "/")
+	if ssh {
+		if ep.Protocol == "ssh" {
+			return rawURL, nil
+		}
+		return fmt.Sprintf("ssh://%s@%s%s", sshUser, ep.Host, path), nil
+	}
+
+	switch ep.Protocol {
+	case "http", "https":
+		return rawURL, nil
+	default:
+		return fmt.Sprintf("https://%s%s", ep.Host, path), nil
+	}
+}
@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"os"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// azureDevOpsCapabilities enables the capabilities Azure DevOps requires once
+// per process.
+var azureDevOpsCapabilities sync.Once
+
+// azureDevOpsCredentials returns the username and password to authenticate to
+// Azure DevOps over HTTP(S) with. Azure DevOps ignores the username of a
+// personal access token, but it must not be empty. The AZURE_DEVOPS_EXT_PAT
+// environment variable of the Azure CLI takes precedence over the configured
+// token.
+func azureDevOpsCredentials(configToken string) (string, string) {
+	token := os.Getenv("AZURE_DEVOPS_EXT_PAT")
+	if token == "" {
+		token = configToken
+	}
+	if token == "" {
+		return "", ""
+	}
+
+	return "pat", token
+}
+
+// enableAzureDevOpsCapabilities allows go-git to talk to Azure DevOps, which
+// only supports clients with the multi_ack capabilities.
+//
+// go-git reads the capabilities from a package variable, so this applies to
+// every repository of every provider configuration in the process, not only
+// to the ones on Azure DevOps, and cannot be undone. go-git does not
+// implement multi_ack for fetches sending the commits the clone already has,
+// so the incremental fetches of resolveBase and fetchPushBranch may fail on
+// servers advertising it. Full clones are not affected.
+func enableAzureDevOpsCapabilities() {
+	azureDevOpsCapabilities.Do(func() {
+		transport.UnsupportedCapabilities = []capability.Capability{
+			capability.ThinPack,
+		}
+	})
+}
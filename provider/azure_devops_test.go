@@ -0,0 +1,33 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+func TestEnableAzureDevOpsCapabilities(t *testing.T) {
+	unsupported := transport.UnsupportedCapabilities
+	t.Cleanup(func() {
+		transport.UnsupportedCapabilities = unsupported
+	})
+
+	enableAzureDevOpsCapabilities()
+
+	// The capabilities are enabled for every transport of the process
+	list := capability.NewList()
+	for _, c := range []capability.Capability{capability.MultiACK, capability.MultiACKDetailed, capability.ThinPack} {
+		if err := list.Set(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	transport.FilterUnsupportedCapabilities(list)
+
+	if !list.Supports(capability.MultiACK) || !list.Supports(capability.MultiACKDetailed) {
+		t.Error("multi_ack capabilities are still filtered")
+	}
+	if list.Supports(capability.ThinPack) {
+		t.Error("thin-pack is not filtered")
+	}
+}
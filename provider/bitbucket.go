@@ -0,0 +1,22 @@
+package provider
+
+import "fmt"
+
+// bitbucketCredentials returns the username and password to authenticate to
+// Bitbucket Cloud over HTTP(S) with. App passwords are only valid together
+// with the username of their owner, while workspace, project and repository
+// access tokens use a fixed username.
+func bitbucketCredentials(username, appPassword, accessToken string) (string, string, error) {
+	if accessToken != "" {
+		return "x-token-auth", accessToken, nil
+	}
+
+	if appPassword != "" {
+		if username == "" {
+			return "", "", fmt.Errorf("bitbucket_username must be set to use bitbucket_app_password")
+		}
+		return username, appPassword, nil
+	}
+
+	return "", "", nil
+}
@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checkRefFormat returns an error if name is not a valid git ref or branch
+// name, following the rules of git check-ref-format.
+func checkRefFormat(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("ref name cannot be empty")
+	case name == "@":
+		return fmt.Errorf("ref name cannot be '@'")
+	case strings.HasPrefix(name, "-"):
+		return fmt.Errorf("ref name cannot begin with '-'")
+	case strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/"):
+		return fmt.Errorf("ref name cannot begin or end with '/'")
+	case strings.HasSuffix(name, "."):
+		return fmt.Errorf("ref name cannot end with '.'")
+	case strings.Contains(name, "//"):
+		return fmt.Errorf("ref name cannot contain '//'")
+	case strings.Contains(name, ".."):
+		return fmt.Errorf("ref name cannot contain '..'")
+	case strings.Contains(name, "@{"):
+		return fmt.Errorf("ref name cannot contain '@{'")
+	}
+
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[\\", r) {
+			return fmt.Errorf("ref name cannot contain %q", r)
+		}
+	}
+
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") {
+			return fmt.Errorf("ref name components cannot begin with '.'")
+		}
+		if strings.HasSuffix(component, ".lock") {
+			return fmt.Errorf("ref name components cannot end with '.lock'")
+		}
+	}
+
+	return nil
+}
+
+// validateBranchName ensures a value is a valid git branch name.
+func validateBranchName(i interface{}, k string) ([]string, []error) {
+	v, ok := i.(string)
+	if !ok {
+		return nil, []error{fmt.Errorf("expected type of %s to be string", k)}
+	}
+
+	if err := checkRefFormat(v); err != nil {
+		return nil, []error{fmt.Errorf("expected %s to be a valid branch name, got %s: %s", k, v, err)}
+	}
+
+	return nil, nil
+}
+
+// validateRefName ensures a value is a valid fully qualified git ref name.
+func validateRefName(i interface{}, k string) ([]string, []error) {
+	v, ok := i.(string)
+	if !ok {
+		return nil, []error{fmt.Errorf("expected type of %s to be string", k)}
+	}
+
+	if !strings.HasPrefix(v, "refs/") {
+		return nil, []error{fmt.Errorf("expected %s to start with refs/, got %s", k, v)}
+	}
+	if err := checkRefFormat(v); err != nil {
+		return nil, []error{fmt.Errorf("expected %s to be a valid ref name, got %s: %s", k, v, err)}
+	}
+
+	return nil, nil
+}
+
+// sanitizeBranchComponent replaces characters that are not allowed in a
+// branch name with '-', so arbitrary values can be used in branch templates.
+func sanitizeBranchComponent(v string) string {
+	v = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(" ~^:?*[\\/", r) {
+			return '-'
+		}
+		return r
+	}, v)
+
+	for strings.Contains(v, "..") {
+		v = strings.ReplaceAll(v, "..", ".")
+	}
+	v = strings.ReplaceAll(v, "@{", "-{")
+	v = strings.TrimSuffix(v, ".lock")
+
+	return strings.Trim(v, ".")
+}
@@ -0,0 +1,165 @@
+package provider
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+const (
+	codecommitSigV4          = "sigv4"
+	codecommitGitCredentials = "git_credentials"
+)
+
+// codecommitHostPattern matches the HTTPS git hosts of CodeCommit, capturing
+// the region.
+var codecommitHostPattern = regexp.MustCompile(`^git-codecommit(?:-fips)?\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?$`)
+
+// codecommitAuth is an HTTP auth method for CodeCommit. Like the
+// git-remote-codecommit helper, it signs every request with SigV4 so no git
+// credentials have to be created for the identity.
+type codecommitAuth struct {
+	credentials aws.CredentialsProvider
+
+	mu     sync.Mutex
+	issued []string
+}
+
+// SetAuth implements http.AuthMethod. Temporary credentials, e.g. of an
+// assumed role, are renewed as needed.
+func (a *codecommitAuth) SetAuth(r *http.Request) {
+	credentials, err := a.credentials.Retrieve(r.Context())
+	if err != nil {
+		failAuth(r, fmt.Errorf("failed to get AWS credentials: %w", err))
+		return
+	}
+	a.mu.Lock()
+	if !slices.Contains(a.issued, credentials.SecretAccessKey) {
+		a.issued = append(a.issued, credentials.SecretAccessKey, credentials.SessionToken)
+	}
+	a.mu.Unlock()
+
+	username, password, ok := codecommitSign(credentials, r.URL.Host, r.URL.Path, time.Now().UTC())
+	if ok {
+		r.SetBasicAuth(username, password)
+	}
+}
+
+// codecommitSign returns the username and password signed with credentials
+// for a request to the repository served below path on host.
+func codecommitSign(credentials aws.Credentials, host, path string, now time.Time) (string, string, bool) {
+	match := codecommitHostPattern.FindStringSubmatch(host)
+	if match == nil {
+		return "", "", false
+	}
+	region := match[1]
+
+	for _, suffix := range []string{"/info/refs", "/" + uploadPackService, "/" + receivePackService} {
+		path = strings.TrimSuffix(path, suffix)
+	}
+
+	timestamp := now.Format("20060102T150405")
+	date := now.Format("20060102")
+	scope := fmt.Sprintf("%s/%s/codecommit/aws4_request", date, region)
+
+	canonicalRequest := fmt.Sprintf("GIT\n%s\n\nhost:%s\n\nhost\n", path, host)
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := fmt.Sprintf("AWS4-HMAC-SHA256\n%s\n%s\n%s", timestamp, scope, hex.EncodeToString(canonicalHash[:]))
+
+	key := []byte("AWS4" + credentials.SecretAccessKey)
+	for _, part := range []string{date, region, "codecommit", "aws4_request", stringToSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+
+	username := credentials.AccessKeyID
+	if credentials.SessionToken != "" {
+		username += "%" + credentials.SessionToken
+	}
+
+	return username, timestamp + "Z" + hex.EncodeToString(key), true
+}
+
+// Name implements transport.AuthMethod.
+func (a *codecommitAuth) Name() string {
+	return "codecommit-sigv4"
+}
+
+func (a *codecommitAuth) String() string {
+	return a.Name()
+}
+
+// tokens returns the secret parts of the AWS credentials requests were signed
+// with, so they can be redacted.
+func (a *codecommitAuth) tokens() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]string(nil), a.issued...)
+}
+
+// isCodeCommitHost reports whether host serves HTTPS CodeCommit
+// repositories.
+func isCodeCommitHost(host string) bool {
+	return codecommitHostPattern.MatchString(host)
+}
+
+// codecommitAuthMethod returns the auth method for CodeCommit repositories
+// from a codecommit block and the password of its git credentials.
+func codecommitAuthMethod(ctx context.Context, item map[string]interface{}, password string) (transport.AuthMethod, error) {
+	if item["auth_mode"].(string) == codecommitGitCredentials {
+		if item["username"].(string) == "" || password == "" {
+			return nil, fmt.Errorf("codecommit username and password must be set to use git credentials")
+		}
+		return &githttp.BasicAuth{
+			Username: item["username"].(string),
+			Password: password,
+		}, nil
+	}
+
+	credentials, err := awsCredentialsProvider(ctx, item["profile"].(string))
+	if err != nil {
+		return nil, err
+	}
+
+	return &codecommitAuth{
+		credentials: credentials,
+	}, nil
+}
+
+// awsCredentialsProvider returns the AWS credentials found like the AWS CLI
+// finds them: in the environment, the shared config and credentials files of
+// the profile, including SSO and assumed roles, or the role of the container
+// or instance. The profile defaults to AWS_PROFILE.
+func awsCredentialsProvider(ctx context.Context, profile string) (aws.CredentialsProvider, error) {
+	var options []func(*config.LoadOptions) error
+	if profile != "" {
+		options = append(options, config.WithSharedConfigProfile(profile))
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+
+	// Fail when configuring the provider rather than on the first request
+	if _, err := awsConfig.Credentials.Retrieve(ctx); err != nil {
+		return nil, fmt.Errorf("failed to find AWS credentials: %w", err)
+	}
+
+	return awsConfig.Credentials, nil
+}
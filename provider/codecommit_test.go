@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAWSCredentialsProvider(t *testing.T) {
+	dir := t.TempDir()
+	credentialsFile := filepath.Join(dir, "credentials")
+	err := os.WriteFile(credentialsFile, []byte("[default]\naws_access_key_id = DEFAULT\naws_secret_access_key = default-secret\n\n[ci]\naws_access_key_id = CI\naws_secret_access_key = ci-secret\naws_session_token = ci-session\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		profile string
+		wantID  string
+		wantErr bool
+	}{
+		{
+			name:   "environment",
+			env:    map[string]string{"AWS_ACCESS_KEY_ID": "ENV", "AWS_SECRET_ACCESS_KEY": "env-secret"},
+			wantID: "ENV",
+		},
+		{
+			name:   "default profile",
+			wantID: "DEFAULT",
+		},
+		{
+			name:    "profile",
+			profile: "ci",
+			wantID:  "CI",
+		},
+		{
+			name:   "AWS_PROFILE",
+			env:    map[string]string{"AWS_PROFILE": "ci"},
+			wantID: "CI",
+		},
+		{
+			name:    "missing profile",
+			profile: "missing",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE"} {
+				t.Setenv(key, tt.env[key])
+			}
+			t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+			t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+			t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+			provider, err := awsCredentialsProvider(context.Background(), tt.profile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("awsCredentialsProvider() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			credentials, err := provider.Retrieve(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if credentials.AccessKeyID != tt.wantID {
+				t.Errorf("AccessKeyID = %s, want %s", credentials.AccessKeyID, tt.wantID)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func resourceCommitValidation() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"deny_patterns": {
+				Description: "Regular expressions that must not match the content of any added file, e.g. to catch secrets.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringIsValidRegExp,
+				},
+			},
+			"max_lines": {
+				Description:  "The maximum number of lines of any added file.",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"command": {
+				Description: "A command run for every added file, with the file content on stdin and its path in the `GIT_FILE_PATH` environment variable. The commit is aborted if the command exits with a non-zero status.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				MinItems:    1,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+// validateFiles checks the add items against their format and the
+// validation block before anything is committed.
+func validateFiles(ctx context.Context, items []interface{}, validationItems []interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for _, item := range items {
+		format := item.(map[string]interface{})["format"].(string)
+		if format == "" {
+			continue
+		}
+		path := item.(map[string]interface{})["path"].(string)
+		content, err := addContent(item.(map[string]interface{}))
+		if err != nil {
+			diags = append(diags, diag.FromErr(err)...)
+			continue
+		}
+		if err := validateFormat(content, format); err != nil {
+			diags = append(diags, diag.Errorf("validation failed for %s: %s", path, err)...)
+		}
+	}
+
+	if len(validationItems) == 0 || validationItems[0] == nil {
+		return diags
+	}
+	v := validationItems[0].(map[string]interface{})
+
+	var denyPatterns []*regexp.Regexp
+	for _, pattern := range v["deny_patterns"].([]interface{}) {
+		re, err := regexp.Compile(pattern.(string))
+		if err != nil {
+			return diag.Errorf("invalid deny pattern %s: %s", pattern, err)
+		}
+		denyPatterns = append(denyPatterns, re)
+	}
+
+	maxLines := v["max_lines"].(int)
+
+	var command []string
+	for _, arg := range v["command"].([]interface{}) {
+		command = append(command, arg.(string))
+	}
+
+	for _, item := range items {
+		path := item.(map[string]interface{})["path"].(string)
+		decoded, err := addContent(item.(map[string]interface{}))
+		if err != nil {
+			diags = append(diags, diag.FromErr(err)...)
+			continue
+		}
+		content := string(decoded)
+
+		for _, re := range denyPatterns {
+			if re.MatchString(content) {
+				diags = append(diags, diag.Errorf("validation failed for %s: content matches deny pattern %s", path, re)...)
+			}
+		}
+
+		if maxLines > 0 {
+			if lines := strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1; lines > maxLines {
+				diags = append(diags, diag.Errorf("validation failed for %s: %d lines exceeds max_lines of %d", path, lines, maxLines)...)
+			}
+		}
+
+		if len(command) > 0 {
+			var output bytes.Buffer
+			cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+			cmd.Stdin = strings.NewReader(content)
+			cmd.Stdout = &output
+			cmd.Stderr = &output
+			cmd.Env = append(os.Environ(), fmt.Sprintf("GIT_FILE_PATH=%s", path))
+
+			if err := cmd.Run(); err != nil {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Error,
+					Summary:  fmt.Sprintf("validation command failed for %s: %s", path, err),
+					Detail:   strings.TrimSpace(output.String()),
+				})
+			}
+		}
+	}
+
+	return diags
+}
@@ -0,0 +1,173 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// credentialHelperTTL is how long the credentials returned by a helper are
+// used before the helper is asked again, so credentials rotated by the helper
+// are picked up during long runs.
+const credentialHelperTTL = time.Minute
+
+// osCredentialHelpers are the credential helpers shipped with git that store
+// credentials in the credential store of the OS, by GOOS. Other systems use
+// libsecret.
+var osCredentialHelpers = map[string]string{
+	"darwin":  "osxkeychain",
+	"windows": "wincred",
+}
+
+// credentialHelperAuth is an HTTP auth method that gets the credentials of
+// each host from a git credential helper, using the git-credential protocol.
+// The credentials are cached per host for credentialHelperTTL.
+type credentialHelperAuth struct {
+	helper string
+
+	mu     sync.Mutex
+	cache  map[string]helperCredentials
+	issued []string
+}
+
+// helperCredentials are the credentials a helper returned for a host.
+type helperCredentials struct {
+	username string
+	password string
+	expiry   time.Time
+}
+
+// SetAuth implements http.AuthMethod. When the helper fails, the previous
+// credentials of the host are sent if there are any, and the request fails
+// with the error of the helper otherwise.
+func (a *credentialHelperAuth) SetAuth(r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := r.URL.Scheme + "://" + r.URL.Host
+	credentials, ok := a.cache[key]
+	if !ok || time.Now().After(credentials.expiry) {
+		username, password, err := a.get(r)
+		if err != nil {
+			if !ok {
+				failAuth(r, err)
+				return
+			}
+			tflog.Warn(r.Context(), "Credential helper failed, using the previous credentials", map[string]interface{}{
+				"host":  r.URL.Host,
+				"error": err.Error(),
+			})
+			r.SetBasicAuth(credentials.username, credentials.password)
+			return
+		}
+
+		credentials = helperCredentials{
+			username: username,
+			password: password,
+			expiry:   time.Now().Add(credentialHelperTTL),
+		}
+		if a.cache == nil {
+			a.cache = make(map[string]helperCredentials)
+		}
+		a.cache[key] = credentials
+		a.issued = append(a.issued, password)
+	}
+
+	r.SetBasicAuth(credentials.username, credentials.password)
+}
+
+// get runs the helper to get the credentials of the host of a request.
+func (a *credentialHelperAuth) get(r *http.Request) (string, string, error) {
+	cmd := credentialHelperCommand(a.helper)
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("protocol=%s\nhost=%s\n\n", r.URL.Scheme, r.URL.Host))
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return "", "", fmt.Errorf("credential helper failed: %w", err)
+	}
+
+	var username, password string
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), "=")
+		switch key {
+		case "username":
+			username = value
+		case "password":
+			password = value
+		}
+	}
+	if password == "" {
+		return "", "", fmt.Errorf("credential helper returned no password for %s", r.URL.Host)
+	}
+
+	return username, password, nil
+}
+
+// tokens returns the passwords the helper returned, so they can be redacted.
+func (a *credentialHelperAuth) tokens() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]string(nil), a.issued...)
+}
+
+// Name implements transport.AuthMethod.
+func (a *credentialHelperAuth) Name() string {
+	return "http-credential-helper"
+}
+
+func (a *credentialHelperAuth) String() string {
+	return fmt.Sprintf("%s - %s", a.Name(), a.helper)
+}
+
+// credentialHelperCommand returns the command of a helper the way git
+// interprets credential.helper: a value starting with ! is a shell command,
+// an absolute path is run as is, and any other name is the suffix of a
+// git-credential-<name> executable.
+func credentialHelperCommand(helper string) *exec.Cmd {
+	switch {
+	case strings.HasPrefix(helper, "!"):
+		if runtime.GOOS == "windows" {
+			return exec.Command("cmd", "/C", helper[1:]+" get")
+		}
+		return exec.Command("sh", "-c", helper[1:]+" get")
+	case filepath.IsAbs(helper):
+		return exec.Command(helper, "get")
+	default:
+		fields := strings.Fields(helper)
+		return exec.Command("git-credential-"+fields[0], append(fields[1:], "get")...)
+	}
+}
+
+// osCredentialHelper returns the path of the credential helper for the
+// credential store of the OS. Like git, the helper is looked up in PATH and
+// in git's exec path, where packages usually install it.
+func osCredentialHelper() (string, error) {
+	helper, ok := osCredentialHelpers[runtime.GOOS]
+	if !ok {
+		helper = "libsecret"
+	}
+	name := "git-credential-" + helper
+
+	if path, err := exec.LookPath(name); err == nil {
+		return path, nil
+	}
+	if execPath, err := exec.Command("git", "--exec-path").Output(); err == nil {
+		if path, err := exec.LookPath(filepath.Join(strings.TrimSpace(string(execPath)), name)); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("credential helper %s for the OS credential store not found", name)
+}
@@ -0,0 +1,61 @@
+package provider
+
+import (
+	"net/http"
+	"runtime"
+	"testing"
+)
+
+func TestCredentialHelperAuth(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the helper is a shell command")
+	}
+
+	tests := []struct {
+		name         string
+		helper       string
+		wantUsername string
+		wantPassword string
+		wantErr      bool
+	}{
+		{
+			name:         "credentials",
+			helper:       `!f() { cat >/dev/null; printf 'protocol=https\nhost=example.com\nusername=octocat\npassword=secret=with=equals\n'; }; f`,
+			wantUsername: "octocat",
+			wantPassword: "secret=with=equals",
+		},
+		{
+			name:    "no password",
+			helper:  `!f() { cat >/dev/null; printf 'username=octocat\n'; }; f`,
+			wantErr: true,
+		},
+		{
+			name:    "failing helper",
+			helper:  "!false",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := &credentialHelperAuth{helper: tt.helper}
+			req, err := http.NewRequest(http.MethodGet, "https://example.com/repo.git/info/refs", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			auth.SetAuth(req)
+
+			if err := authError(req); (err != nil) != tt.wantErr {
+				t.Fatalf("SetAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			username, password, _ := req.BasicAuth()
+			if username != tt.wantUsername || password != tt.wantPassword {
+				t.Errorf("SetAuth() credentials = %s:%s, want %s:%s", username, password, tt.wantUsername, tt.wantPassword)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// branchTemplatePattern matches the placeholders of a branch name template.
+var branchTemplatePattern = regexp.MustCompile(`\{(\w+)\}`)
+
+func dataBranchName() *schema.Resource {
+	return &schema.Resource{
+		Description: "Renders and validates a git branch name from a template.",
+		ReadContext: dataBranchNameRead,
+		Schema: map[string]*schema.Schema{
+			"template": {
+				Description: "The branch name template. Placeholders in the form `{name}` are replaced with the value of the matching key in `vars`, e.g. `tf/{workspace}/{name}`.",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+			"vars": {
+				Description: "The values of the template placeholders.",
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"sanitize": {
+				Description: "Replace characters that are not allowed in branch names in the values of `vars` with `-`.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+
+			"name": {
+				Description: "The rendered branch name.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func dataBranchNameRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	template := d.Get("template").(string)
+	vars := d.Get("vars").(map[string]interface{})
+	sanitize := d.Get("sanitize").(bool)
+
+	var missing []string
+	name := branchTemplatePattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		key := branchTemplatePattern.FindStringSubmatch(placeholder)[1]
+
+		value, ok := vars[key]
+		if !ok {
+			missing = append(missing, key)
+			return placeholder
+		}
+
+		if sanitize {
+			return sanitizeBranchComponent(value.(string))
+		}
+		return value.(string)
+	})
+	if len(missing) > 0 {
+		return diag.Errorf("missing value for template placeholders: %v", missing)
+	}
+
+	if err := checkRefFormat(name); err != nil {
+		return diag.Errorf("invalid branch name %s: %s", name, err)
+	}
+
+	d.SetId(name)
+	if err := d.Set("name", name); err != nil {
+		return diag.Errorf("failed to set name: %s", err)
+	}
+
+	return nil
+}
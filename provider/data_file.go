@@ -7,27 +7,28 @@ import (
 	"io"
 	"io/fs"
 	"path/filepath"
+	"time"
 
 	"github.com/go-git/go-billy/v5/memfs"
 	gogit "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
-	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 func dataFile() *schema.Resource {
 	return &schema.Resource{
 		Description: "A file in a remote repository.",
-		ReadContext: dataFileRead,
+		ReadContext: redacted(dataFileRead),
+		Timeouts: &schema.ResourceTimeout{
+			Read: schema.DefaultTimeout(10 * time.Minute),
+		},
 		Schema: map[string]*schema.Schema{
 			"url": {
 				Type:         schema.TypeString,
 				Required:     true,
 				ForceNew:     true,
-				ValidateFunc: validation.IsURLWithScheme([]string{"http", "https", "ssh"}),
+				ValidateFunc: validateRepoURL,
 			},
 			"ref": {
 				Type:     schema.TypeString,
@@ -50,12 +51,9 @@ func dataFileRead(ctx context.Context, d *schema.ResourceData, meta interface{})
 	url := d.Get("url").(string)
 	path := d.Get("path").(string)
 
-	auth := meta.(*http.BasicAuth)
+	cfg := meta.(*providerConfig)
 
-	repo, err := gogit.CloneContext(ctx, memory.NewStorage(), memfs.New(), &gogit.CloneOptions{
-		URL:  url,
-		Auth: auth,
-	})
+	repo, err := cfg.clone(ctx, url, gogit.DefaultRemoteName, memfs.New())
 	if err != nil {
 		return diag.Errorf("failed to clone repository: %s", err)
 	}
@@ -70,7 +68,7 @@ func dataFileRead(ctx context.Context, d *schema.ResourceData, meta interface{})
 		ref := refI.(string)
 
 		// Resolve then checkout the specified ref
-		sha, err := repo.ResolveRevision(plumbing.Revision(fmt.Sprintf("origin/%s", ref)))
+		sha, err := repo.ResolveRevision(plumbing.Revision(fmt.Sprintf("%s/%s", gogit.DefaultRemoteName, ref)))
 		if err != nil && errors.Is(err, plumbing.ErrReferenceNotFound) {
 			sha, err = repo.ResolveRevision(plumbing.Revision(ref))
 		}
@@ -78,10 +76,7 @@ func dataFileRead(ctx context.Context, d *schema.ResourceData, meta interface{})
 			return diag.Errorf("failed to resolve ref %s: %s", ref, err)
 		}
 
-		err = worktree.Checkout(&gogit.CheckoutOptions{
-			Hash:  *sha,
-			Force: true,
-		})
+		err = checkoutCommit(worktree, *sha)
 		if err != nil {
 			return diag.Errorf("failed to checkout commit %s: %s", sha.String(), err)
 		}
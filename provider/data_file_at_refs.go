@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataFileAtRefs() *schema.Resource {
+	return &schema.Resource{
+		Description: "A file in a remote repository, read at several refs with a single clone.",
+		ReadContext: redacted(dataFileAtRefsRead),
+		Timeouts: &schema.ResourceTimeout{
+			Read: schema.DefaultTimeout(10 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"url": {
+				Description:  "The URL of the git repository. Must be an http, https or ssh URL, an scp-like SSH URL such as `git@github.com:org/repo.git`, or the file URL or absolute path of a local repository or bundle file.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateRepoURL,
+			},
+			"path": {
+				Description:  "The path of the file.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateRepoPath,
+			},
+			"refs": {
+				Description: "The branches, tags or commit shas to read the file at.",
+				Type:        schema.TypeList,
+				Required:    true,
+				MinItems:    1,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+
+			"contents": {
+				Description: "The content of the file by ref. Refs where the file does not exist are omitted.",
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"missing": {
+				Description: "The refs where the file does not exist.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+func dataFileAtRefsRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	url := d.Get("url").(string)
+	path := d.Get("path").(string)
+
+	var refs []string
+	for _, ref := range d.Get("refs").([]interface{}) {
+		refs = append(refs, ref.(string))
+	}
+
+	cfg := meta.(*providerConfig)
+
+	repo, err := cfg.clone(ctx, url, gogit.DefaultRemoteName, nil)
+	if err != nil {
+		return diag.Errorf("failed to clone repository: %s", err)
+	}
+
+	contents := make(map[string]string)
+	missing := []string{}
+	for _, ref := range refs {
+		sha, err := resolveRef(repo, gogit.DefaultRemoteName, ref)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		commit, err := repo.CommitObject(*sha)
+		if err != nil {
+			return diag.Errorf("failed to get commit %s: %s", sha, err)
+		}
+		tree, err := commit.Tree()
+		if err != nil {
+			return diag.Errorf("failed to get tree of %s: %s", sha, err)
+		}
+
+		content, err := treeFileContent(tree, path)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		if content == nil {
+			missing = append(missing, ref)
+			continue
+		}
+		contents[ref] = string(content)
+	}
+
+	d.SetId(fmt.Sprintf("%s:%s@%s", url, path, strings.Join(refs, ",")))
+	if err := d.Set("contents", contents); err != nil {
+		return diag.Errorf("failed to set contents: %s", err)
+	}
+	if err := d.Set("missing", missing); err != nil {
+		return diag.Errorf("failed to set missing: %s", err)
+	}
+
+	return nil
+}
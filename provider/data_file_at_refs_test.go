@@ -0,0 +1,43 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataFileAtRefs(t *testing.T) {
+	url := newTestRemote(t)
+	applyTestCommit(t, nil, url, map[string]interface{}{
+		"add": []interface{}{map[string]interface{}{"path": "other", "content": "other"}},
+	})
+	before := remoteCommit(t, url, "refs/heads/main").Hash.String()
+	applyTestCommit(t, nil, url, map[string]interface{}{
+		"add": []interface{}{map[string]interface{}{"path": "file", "content": "main"}},
+	})
+	applyTestCommit(t, nil, url, map[string]interface{}{
+		"branch":        "feature",
+		"create_branch": true,
+		"add":           []interface{}{map[string]interface{}{"path": "file", "content": "feature"}},
+	})
+
+	d := schema.TestResourceDataRaw(t, dataFileAtRefs().Schema, map[string]interface{}{
+		"url":  url,
+		"path": "file",
+		"refs": []interface{}{"main", before, "feature"},
+	})
+	if diags := dataFileAtRefsRead(context.Background(), d, &providerConfig{}); diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	wantContents := map[string]interface{}{"main": "main", "feature": "feature"}
+	if got := d.Get("contents").(map[string]interface{}); !reflect.DeepEqual(got, wantContents) {
+		t.Errorf("contents = %v, want %v", got, wantContents)
+	}
+	wantMissing := []interface{}{before}
+	if got := d.Get("missing").([]interface{}); !reflect.DeepEqual(got, wantMissing) {
+		t.Errorf("missing = %v, want %v", got, wantMissing)
+	}
+}
@@ -0,0 +1,155 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func dataMergeCheck() *schema.Resource {
+	return &schema.Resource{
+		Description: "Checks whether merging one ref of a remote repository into another would be clean.",
+		ReadContext: redacted(dataMergeCheckRead),
+		Timeouts: &schema.ResourceTimeout{
+			Read: schema.DefaultTimeout(10 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"url": {
+				Description:  "The URL of the git repository. Must be an http, https or ssh URL, an scp-like SSH URL such as `git@github.com:org/repo.git`, or the file URL or absolute path of a local repository or bundle file.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateRepoURL,
+			},
+			"base": {
+				Description: "The branch, tag or commit sha to merge into.",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+			"head": {
+				Description: "The branch, tag or commit sha to merge.",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+
+			"merge_base": {
+				Description: "The sha of the best common ancestor of base and head.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"up_to_date": {
+				Description: "Whether base already contains head, so there is nothing to merge.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			"fast_forward": {
+				Description: "Whether base can be fast-forwarded to head.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			"clean": {
+				Description: "Whether head can be merged into base without conflicts.",
+				Type:        schema.TypeBool,
+				Computed:    true,
+			},
+			"conflicts": {
+				Description: "The paths that conflict when merging head into base.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+func dataMergeCheckRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	url := d.Get("url").(string)
+	base := d.Get("base").(string)
+	head := d.Get("head").(string)
+
+	cfg := meta.(*providerConfig)
+
+	repo, err := cfg.clone(ctx, url, gogit.DefaultRemoteName, nil)
+	if err != nil {
+		return diag.Errorf("failed to clone repository: %s", err)
+	}
+
+	// Resolve both sides
+	baseSha, err := resolveRef(repo, gogit.DefaultRemoteName, base)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	headSha, err := resolveRef(repo, gogit.DefaultRemoteName, head)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	baseCommit, err := repo.CommitObject(*baseSha)
+	if err != nil {
+		return diag.Errorf("failed to get commit %s: %s", baseSha, err)
+	}
+	headCommit, err := repo.CommitObject(*headSha)
+	if err != nil {
+		return diag.Errorf("failed to get commit %s: %s", headSha, err)
+	}
+
+	// Find the merge base
+	mergeBases, err := headCommit.MergeBase(baseCommit)
+	if err != nil {
+		return diag.Errorf("failed to compute merge base: %s", err)
+	}
+	if len(mergeBases) == 0 {
+		return diag.Errorf("%s and %s have no common history", base, head)
+	}
+	mergeBase := mergeBases[0]
+
+	upToDate := mergeBase.Hash == headCommit.Hash
+	fastForward := !upToDate && mergeBase.Hash == baseCommit.Hash
+
+	// Compare the changes made on both sides since the merge base
+	conflicts := []string{}
+	if !upToDate && !fastForward {
+		mergeBaseTree, err := mergeBase.Tree()
+		if err != nil {
+			return diag.Errorf("failed to get tree of %s: %s", mergeBase.Hash, err)
+		}
+		baseTree, err := baseCommit.Tree()
+		if err != nil {
+			return diag.Errorf("failed to get tree of %s: %s", baseCommit.Hash, err)
+		}
+		headTree, err := headCommit.Tree()
+		if err != nil {
+			return diag.Errorf("failed to get tree of %s: %s", headCommit.Hash, err)
+		}
+
+		paths, err := mergeConflicts(mergeBaseTree, baseTree, headTree)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		conflicts = append(conflicts, paths...)
+	}
+
+	d.SetId(fmt.Sprintf("%s:%s", baseSha, headSha))
+	if err := d.Set("merge_base", mergeBase.Hash.String()); err != nil {
+		return diag.Errorf("failed to set merge_base: %s", err)
+	}
+	if err := d.Set("up_to_date", upToDate); err != nil {
+		return diag.Errorf("failed to set up_to_date: %s", err)
+	}
+	if err := d.Set("fast_forward", fastForward); err != nil {
+		return diag.Errorf("failed to set fast_forward: %s", err)
+	}
+	if err := d.Set("clean", len(conflicts) == 0); err != nil {
+		return diag.Errorf("failed to set clean: %s", err)
+	}
+	if err := d.Set("conflicts", conflicts); err != nil {
+		return diag.Errorf("failed to set conflicts: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataMergeCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		main          map[string]interface{}
+		feature       map[string]interface{}
+		wantClean     bool
+		wantConflicts []interface{}
+	}{
+		{
+			name:      "clean",
+			main:      map[string]interface{}{"path": "main", "content": "main"},
+			feature:   map[string]interface{}{"path": "feature", "content": "feature"},
+			wantClean: true,
+		},
+		{
+			name:          "conflicting",
+			main:          map[string]interface{}{"path": "shared", "content": "main"},
+			feature:       map[string]interface{}{"path": "shared", "content": "feature"},
+			wantConflicts: []interface{}{"shared"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := newTestRemote(t)
+			applyTestCommit(t, nil, url, map[string]interface{}{
+				"add": []interface{}{map[string]interface{}{"path": "shared", "content": "base"}},
+			})
+			mergeBase := remoteCommit(t, url, "refs/heads/main").Hash.String()
+			applyTestCommit(t, nil, url, map[string]interface{}{
+				"branch":        "feature",
+				"create_branch": true,
+				"add":           []interface{}{tt.feature},
+			})
+			applyTestCommit(t, nil, url, map[string]interface{}{
+				"add": []interface{}{tt.main},
+			})
+
+			d := schema.TestResourceDataRaw(t, dataMergeCheck().Schema, map[string]interface{}{
+				"url":  url,
+				"base": "main",
+				"head": "feature",
+			})
+			if diags := dataMergeCheckRead(context.Background(), d, &providerConfig{}); diags.HasError() {
+				t.Fatal(diags)
+			}
+
+			if got := d.Get("merge_base").(string); got != mergeBase {
+				t.Errorf("merge_base = %s, want %s", got, mergeBase)
+			}
+			if d.Get("up_to_date").(bool) || d.Get("fast_forward").(bool) {
+				t.Errorf("up_to_date = %t, fast_forward = %t, want a diverged merge", d.Get("up_to_date"), d.Get("fast_forward"))
+			}
+			if got := d.Get("clean").(bool); got != tt.wantClean {
+				t.Errorf("clean = %t, want %t", got, tt.wantClean)
+			}
+			if got := d.Get("conflicts").([]interface{}); len(got) != len(tt.wantConflicts) || (len(got) > 0 && !reflect.DeepEqual(got, tt.wantConflicts)) {
+				t.Errorf("conflicts = %v, want %v", got, tt.wantConflicts)
+			}
+		})
+	}
+}
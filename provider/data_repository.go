@@ -2,26 +2,27 @@ package provider
 
 import (
 	"context"
+	"time"
 
 	gogit "github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
-	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 func dataRepository() *schema.Resource {
 	return &schema.Resource{
 		Description: "A remote git repository.",
-		ReadContext: dataRepositoryRead,
+		ReadContext: redacted(dataRepositoryRead),
+		Timeouts: &schema.ResourceTimeout{
+			Read: schema.DefaultTimeout(10 * time.Minute),
+		},
 		Schema: map[string]*schema.Schema{
 			"url": {
-				Description:  "The URL of the git repository. Must be http, https, or ssh.",
+				Description:  "The URL of the git repository. Must be an http, https or ssh URL, an scp-like SSH URL such as `git@github.com:org/repo.git`, or the file URL or absolute path of a local repository or bundle file.",
 				Type:         schema.TypeString,
 				Required:     true,
 				ForceNew:     true,
-				ValidateFunc: validation.IsURLWithScheme([]string{"http", "https", "ssh"}),
+				ValidateFunc: validateRepoURL,
 			},
 			"head": {
 				Description: "The head of the git repository.",
@@ -77,12 +78,9 @@ func dataRepository() *schema.Resource {
 func dataRepositoryRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	url := d.Get("url").(string)
 
-	auth := meta.(*http.BasicAuth)
+	cfg := meta.(*providerConfig)
 
-	repo, err := gogit.CloneContext(ctx, memory.NewStorage(), nil, &gogit.CloneOptions{
-		URL:  url,
-		Auth: auth,
-	})
+	repo, err := cfg.clone(ctx, url, gogit.DefaultRemoteName, nil)
 	if err != nil {
 		return diag.Errorf("failed to clone repository: %s", err)
 	}
@@ -103,14 +101,7 @@ func dataRepositoryRead(ctx context.Context, d *schema.ResourceData, meta interf
 	}
 
 	// Fetch all remote refs
-	remote, err := repo.Remote("origin")
-	if err != nil {
-		return diag.Errorf("failed to retrieve remote: %s", err)
-	}
-
-	refs, err := remote.ListContext(ctx, &gogit.ListOptions{
-		Auth: auth,
-	})
+	refs, err := cfg.listRefs(ctx, repo, gogit.DefaultRemoteName)
 	if err != nil {
 		return diag.Errorf("failed to list remote refs: %s", err)
 	}
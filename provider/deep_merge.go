@@ -0,0 +1,289 @@
+package provider
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/util"
+	gogit "github.com/go-git/go-git/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// itemFormat returns the format of an add item: its format or, when it is
+// not set, the one of the extension of its path.
+func itemFormat(item map[string]interface{}) (string, error) {
+	if format := item["format"].(string); format != "" {
+		return format, nil
+	}
+
+	switch strings.ToLower(filepath.Ext(item["path"].(string))) {
+	case ".json":
+		return formatJSON, nil
+	case ".yaml", ".yml":
+		return formatYAML, nil
+	}
+
+	return "", fmt.Errorf("failed to merge %s: set its format to yaml or json", item["path"])
+}
+
+// deepMergeContent returns the content of the file at path in worktree with
+// the content of the add item deep merged into it. It returns false if the
+// file already holds the data of the content.
+func deepMergeContent(worktree *gogit.Worktree, path string, item map[string]interface{}) ([]byte, bool, error) {
+	format, err := itemFormat(item)
+	if err != nil {
+		return nil, false, err
+	}
+	content, err := addContent(item)
+	if err != nil {
+		return nil, false, err
+	}
+
+	existing, err := util.ReadFile(worktree.Filesystem, path)
+	if errors.Is(err, os.ErrNotExist) {
+		return content, true, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	var merged []byte
+	switch format {
+	case formatJSON:
+		merged, err = deepMergeJSON(existing, content)
+	default:
+		merged, err = deepMergeYAML(existing, content)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to merge file %s: %w", path, err)
+	}
+	if semanticallyEqual(merged, existing, format) {
+		return nil, false, nil
+	}
+
+	return merged, true, nil
+}
+
+// unmergeContent returns the content of the file at path in worktree without
+// the keys of the content of the add item, and false if the file only held
+// them.
+func unmergeContent(worktree *gogit.Worktree, path string, item map[string]interface{}) ([]byte, bool, error) {
+	format, err := itemFormat(item)
+	if err != nil {
+		return nil, false, err
+	}
+	content, err := addContent(item)
+	if err != nil {
+		return nil, false, err
+	}
+
+	existing, err := util.ReadFile(worktree.Filesystem, path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	switch format {
+	case formatJSON:
+		return unmergeJSON(existing, content)
+	default:
+		return unmergeYAML(existing, content)
+	}
+}
+
+// deepMergeJSON merges the objects of content into the ones of existing,
+// keeping their other keys. Other values of content replace the ones of
+// existing, and null values remove keys.
+func deepMergeJSON(existing, content []byte) ([]byte, error) {
+	document, err := decodeJSON(existing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse json: %w", err)
+	}
+	patch, err := decodeJSON(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse content: %w", err)
+	}
+
+	return encodeJSON(mergePatch(document, patch), existing)
+}
+
+// unmergeJSON removes the keys of the objects of content from existing.
+func unmergeJSON(existing, content []byte) ([]byte, bool, error) {
+	document, err := decodeJSON(existing)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse json: %w", err)
+	}
+	merged, err := decodeJSON(content)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse content: %w", err)
+	}
+
+	object, ok := document.(*jsonObject)
+	if !ok || !unmergeJSONObject(object, merged) {
+		return nil, false, nil
+	}
+	encoded, err := encodeJSON(object, existing)
+
+	return encoded, true, err
+}
+
+// unmergeJSONObject removes the keys of merged from object, and reports
+// whether object has keys left.
+func unmergeJSONObject(object *jsonObject, merged interface{}) bool {
+	mergedObject, ok := merged.(*jsonObject)
+	if !ok {
+		return false
+	}
+
+	for _, key := range mergedObject.keys {
+		value, ok := object.get(key)
+		if !ok {
+			continue
+		}
+		if nested, ok := value.(*jsonObject); ok && unmergeJSONObject(nested, mergedObject.values[key]) {
+			continue
+		}
+		object.delete(key)
+	}
+
+	return len(object.keys) > 0
+}
+
+// deepMergeYAML merges the mappings of the first document of content into
+// the ones of the first document of existing, keeping their other keys and
+// comments. Other values of content replace the ones of existing.
+func deepMergeYAML(existing, content []byte) ([]byte, error) {
+	documents, err := decodeYAMLNodes(existing)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := decodeYAMLNodes(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse content: %w", err)
+	}
+	if len(merged) == 0 {
+		return existing, nil
+	}
+	if len(documents) == 0 {
+		return content, nil
+	}
+
+	mergeYAMLNodes(documents[0].Content[0], merged[0].Content[0])
+
+	return encodeYAMLNodes(documents)
+}
+
+// unmergeYAML removes the keys of the mappings of the first document of
+// content from the first document of existing.
+func unmergeYAML(existing, content []byte) ([]byte, bool, error) {
+	documents, err := decodeYAMLNodes(existing)
+	if err != nil {
+		return nil, false, err
+	}
+	merged, err := decodeYAMLNodes(content)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse content: %w", err)
+	}
+	if len(documents) == 0 || len(merged) == 0 {
+		return existing, len(documents) > 0, nil
+	}
+
+	if !unmergeYAMLNodes(documents[0].Content[0], merged[0].Content[0]) && len(documents) == 1 {
+		return nil, false, nil
+	}
+	encoded, err := encodeYAMLNodes(documents)
+
+	return encoded, true, err
+}
+
+// mergeYAMLNodes merges the mapping src into dst.
+func mergeYAMLNodes(dst, src *yaml.Node) {
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		*dst = *src
+		return
+	}
+
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		found := false
+		for j := 0; j+1 < len(dst.Content); j += 2 {
+			if dst.Content[j].Value == key.Value {
+				if dst.Content[j+1].Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+					mergeYAMLNodes(dst.Content[j+1], value)
+				} else {
+					// The comments of the replaced value are kept
+					value.HeadComment, value.LineComment, value.FootComment = dst.Content[j+1].HeadComment, dst.Content[j+1].LineComment, dst.Content[j+1].FootComment
+					dst.Content[j+1] = value
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst.Content = append(dst.Content, key, value)
+		}
+	}
+}
+
+// unmergeYAMLNodes removes the keys of the mapping src from dst, and reports
+// whether dst has keys left.
+func unmergeYAMLNodes(dst, src *yaml.Node) bool {
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		return false
+	}
+
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		for j := 0; j+1 < len(dst.Content); j += 2 {
+			if dst.Content[j].Value != src.Content[i].Value {
+				continue
+			}
+			if !unmergeYAMLNodes(dst.Content[j+1], src.Content[i+1]) {
+				dst.Content = append(dst.Content[:j], dst.Content[j+2:]...)
+			}
+			break
+		}
+	}
+
+	return len(dst.Content) > 0
+}
+
+// decodeYAMLNodes decodes the documents of content.
+func decodeYAMLNodes(content []byte) ([]*yaml.Node, error) {
+	var documents []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var document yaml.Node
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse yaml: %w", err)
+		}
+		if len(document.Content) == 0 {
+			document.Content = append(document.Content, &yaml.Node{Kind: yaml.MappingNode})
+		}
+		documents = append(documents, &document)
+	}
+
+	return documents, nil
+}
+
+// encodeYAMLNodes encodes documents, indented by two spaces.
+func encodeYAMLNodes(documents []*yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	for _, document := range documents {
+		if err := encoder.Encode(document); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,150 @@
+package provider
+
+import (
+	"testing"
+)
+
+func TestDeepMergeYAML(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		content  string
+		want     string
+	}{
+		{
+			name:     "nested mappings",
+			existing: "# settings\nmetadata:\n  name: app\n  labels:\n    team: a\n",
+			content:  "metadata:\n  labels:\n    env: prod\n",
+			want:     "# settings\nmetadata:\n  name: app\n  labels:\n    team: a\n    env: prod\n",
+		},
+		{
+			name:     "replaced value keeps its comment",
+			existing: "replicas: 1 # scaled by hpa\n",
+			content:  "replicas: 3\n",
+			want:     "replicas: 3 # scaled by hpa\n",
+		},
+		{
+			name:     "sequences are replaced",
+			existing: "args:\n  - a\n  - b\n",
+			content:  "args:\n  - c\n",
+			want:     "args:\n  - c\n",
+		},
+		{
+			name:     "empty file",
+			existing: "",
+			content:  "name: app\n",
+			want:     "name: app\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := deepMergeYAML([]byte(tt.existing), []byte(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("deepMergeYAML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmergeYAML(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		content  string
+		want     string
+		wantLeft bool
+	}{
+		{
+			name:     "merged keys are removed",
+			existing: "metadata:\n  name: app\n  labels:\n    team: a\n    env: prod\n",
+			content:  "metadata:\n  labels:\n    env: prod\n",
+			want:     "metadata:\n  name: app\n  labels:\n    team: a\n",
+			wantLeft: true,
+		},
+		{
+			name:     "emptied mappings are removed",
+			existing: "name: app\nlabels:\n  env: prod\n",
+			content:  "labels:\n  env: prod\n",
+			want:     "name: app\n",
+			wantLeft: true,
+		},
+		{
+			name:     "only merged keys",
+			existing: "labels:\n  env: prod\n",
+			content:  "labels:\n  env: prod\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, left, err := unmergeYAML([]byte(tt.existing), []byte(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if left != tt.wantLeft {
+				t.Fatalf("unmergeYAML() left = %v, want %v", left, tt.wantLeft)
+			}
+			if left && string(got) != tt.want {
+				t.Errorf("unmergeYAML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeepMergeJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		content  string
+		want     string
+	}{
+		{
+			name:     "nested objects keep their key order",
+			existing: "{\n  \"b\": 1,\n  \"a\": {\n    \"x\": 1\n  }\n}\n",
+			content:  `{"a": {"y": 2}}`,
+			want:     "{\n  \"b\": 1,\n  \"a\": {\n    \"x\": 1,\n    \"y\": 2\n  }\n}\n",
+		},
+		{
+			name:     "null removes keys",
+			existing: `{"a":1,"b":2}`,
+			content:  `{"b":null}`,
+			want:     `{"a":1}`,
+		},
+		{
+			name:     "arrays are replaced",
+			existing: `{"a":[1,2]}`,
+			content:  `{"a":[3]}`,
+			want:     `{"a":[3]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := deepMergeJSON([]byte(tt.existing), []byte(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("deepMergeJSON() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmergeJSON(t *testing.T) {
+	got, left, err := unmergeJSON([]byte(`{"a":{"x":1,"y":2},"b":1}`), []byte(`{"a":{"y":2},"b":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !left || string(got) != `{"a":{"x":1}}` {
+		t.Errorf("unmergeJSON() = %q, %v, want %q, true", got, left, `{"a":{"x":1}}`)
+	}
+
+	if _, left, err := unmergeJSON([]byte(`{"a":{"y":2}}`), []byte(`{"a":{"y":2}}`)); err != nil || left {
+		t.Errorf("unmergeJSON() left = %v, error = %v, want false, nil", left, err)
+	}
+}
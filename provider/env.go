@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// envPrefix is the prefix of the environment variables setting provider
+// arguments, e.g. GIT_PROVIDER_TOKEN for token.
+const envPrefix = "GIT_PROVIDER_"
+
+// envName returns the environment variable setting the provider argument key.
+func envName(key string) string {
+	return envPrefix + strings.ToUpper(key)
+}
+
+// addEnvDefaults makes every string, bool and number argument of s default
+// to its environment variable, so modules can be reused across environments
+// without passing provider settings through variables.
+func addEnvDefaults(s map[string]*schema.Schema) {
+	for key, attr := range s {
+		switch attr.Type {
+		case schema.TypeString, schema.TypeBool, schema.TypeInt, schema.TypeFloat:
+		default:
+			continue
+		}
+		if attr.DefaultFunc != nil {
+			continue
+		}
+
+		attr.DefaultFunc = schema.EnvDefaultFunc(envName(key), attr.Default)
+		attr.Default = nil
+		attr.Description += fmt.Sprintf(" Can also be set with the `%s` environment variable.", envName(key))
+	}
+}
+
+// envIdentity returns the identity in the NAME and EMAIL environment
+// variables of the provider block key or, when neither is set, in the ones git
+// uses, e.g. GIT_AUTHOR_NAME and GIT_AUTHOR_EMAIL. It returns nil if none are
+// set. As git combines its variables with the git configuration, they are only
+// used when both are set.
+func envIdentity(key string) (*object.Signature, error) {
+	nameVar, emailVar := envName(key+"_name"), envName(key+"_email")
+	name, email := os.Getenv(nameVar), os.Getenv(emailVar)
+	if name == "" && email == "" {
+		name = os.Getenv("GIT_" + strings.ToUpper(key) + "_NAME")
+		email = os.Getenv("GIT_" + strings.ToUpper(key) + "_EMAIL")
+		if name == "" || email == "" {
+			return nil, nil
+		}
+	}
+	if name == "" || email == "" {
+		return nil, fmt.Errorf("both %s and %s must be set", nameVar, emailVar)
+	}
+
+	return &object.Signature{
+		Name:  name,
+		Email: email,
+	}, nil
+}
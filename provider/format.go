@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The formats of add items.
+const (
+	formatYAML = "yaml"
+	formatJSON = "json"
+)
+
+// parseDocuments parses the documents of content in format. JSON content is a
+// single document, while YAML content can contain several.
+func parseDocuments(content []byte, format string) ([]interface{}, error) {
+	var documents []interface{}
+	switch format {
+	case formatJSON:
+		var document interface{}
+		if err := json.Unmarshal(content, &document); err != nil {
+			return nil, fmt.Errorf("failed to parse json: %w", err)
+		}
+		documents = append(documents, document)
+	case formatYAML:
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		for {
+			var document interface{}
+			err := decoder.Decode(&document)
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to parse yaml: %w", err)
+			}
+			documents = append(documents, document)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format %s", format)
+	}
+
+	return documents, nil
+}
+
+// validateFormat checks that content is valid in format, returning the line
+// and column of syntax errors.
+func validateFormat(content []byte, format string) error {
+	if format == formatJSON {
+		// Unlike yaml errors, json errors only have an offset
+		var syntaxErr *json.SyntaxError
+		if err := json.Unmarshal(content, new(interface{})); errors.As(err, &syntaxErr) {
+			before := content[:syntaxErr.Offset]
+			line := bytes.Count(before, []byte("\n")) + 1
+			column := len(before) - bytes.LastIndexByte(before, '\n') - 1
+			return fmt.Errorf("invalid json at line %d, column %d: %s", line, column, syntaxErr)
+		}
+	}
+
+	_, err := parseDocuments(content, format)
+	return err
+}
+
+// semanticallyEqual reports whether the content a and b in format hold the
+// same data, regardless of key order, quoting or indentation.
+func semanticallyEqual(a, b []byte, format string) bool {
+	documentsA, err := parseDocuments(a, format)
+	if err != nil {
+		return false
+	}
+	documentsB, err := parseDocuments(b, format)
+	if err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(documentsA, documentsB)
+}
+
+// canonicalContent returns the data of content in format encoded as JSON,
+// with sorted keys, or content itself if it cannot be parsed.
+func canonicalContent(content, format string) string {
+	documents, err := parseDocuments([]byte(content), format)
+	if err != nil {
+		return content
+	}
+	canonical, err := json.Marshal(documents)
+	if err != nil {
+		return content
+	}
+
+	return string(canonical)
+}
@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// githubInstallationTokenLifetime is how long installation tokens are valid
+// when the exchange does not say.
+const githubInstallationTokenLifetime = time.Hour
+
+// githubActionsOIDC exchanges the OIDC token of a GitHub Actions job for a
+// GitHub App installation token, using a token exchange service such as
+// octo-sts that holds the private key of the app.
+type githubActionsOIDC struct {
+	exchangeURL string
+	audience    string
+	scope       string
+	identity    string
+	client      *http.Client
+}
+
+// idToken requests an OIDC token for the running GitHub Actions job.
+func (o *githubActionsOIDC) idToken(ctx context.Context) (string, error) {
+	requestURL, requestToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL"), os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if requestURL == "" || requestToken == "" {
+		return "", fmt.Errorf("no GitHub Actions OIDC token available: the job needs the id-token: write permission")
+	}
+
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid ACTIONS_ID_TOKEN_REQUEST_URL: %w", err)
+	}
+	if o.audience != "" {
+		query := u.Query()
+		query.Set("audience", o.audience)
+		u.RawQuery = query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+requestToken)
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get GitHub Actions OIDC token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get GitHub Actions OIDC token: %s", resp.Status)
+	}
+
+	var body struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode GitHub Actions OIDC token: %w", err)
+	}
+
+	return body.Value, nil
+}
+
+// installationToken implements tokenSource, exchanging a new OIDC token for
+// an installation token.
+func (o *githubActionsOIDC) installationToken(ctx context.Context) (string, time.Time, error) {
+	idToken, err := o.idToken(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	u, err := url.Parse(o.exchangeURL)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid exchange url: %w", err)
+	}
+	query := u.Query()
+	if o.scope != "" {
+		query.Set("scope", o.scope)
+	}
+	if o.identity != "" {
+		query.Set("identity", o.identity)
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+idToken)
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to exchange GitHub Actions OIDC token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+		Message   string    `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to decode exchanged token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Token == "" {
+		return "", time.Time{}, fmt.Errorf("failed to exchange GitHub Actions OIDC token: %s: %s", resp.Status, body.Message)
+	}
+
+	if body.ExpiresAt.IsZero() {
+		body.ExpiresAt = time.Now().Add(githubInstallationTokenLifetime)
+	}
+
+	return body.Token, body.ExpiresAt, nil
+}
@@ -0,0 +1,21 @@
+package provider
+
+import "os"
+
+// gitlabCredentials returns the username and token to authenticate to GitLab
+// over HTTP(S) with. The GITLAB_TOKEN environment variable takes precedence
+// over the configured token, and the job token of GitLab CI is used when
+// neither is set.
+func gitlabCredentials(configToken string) (string, string) {
+	if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+		return "oauth2", token
+	}
+	if configToken != "" {
+		return "oauth2", configToken
+	}
+	if token := os.Getenv("CI_JOB_TOKEN"); token != "" {
+		return "gitlab-ci-token", token
+	}
+
+	return "", ""
+}
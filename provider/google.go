@@ -0,0 +1,42 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// googleSourceHost is the host of Cloud Source Repositories.
+	googleSourceHost = "source.developers.google.com"
+
+	googleScope = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// googleTokenSource returns a source of OAuth access tokens from Application
+// Default Credentials, found like gcloud and the Google Cloud client libraries
+// find them: the file in GOOGLE_APPLICATION_CREDENTIALS, the credentials of
+// `gcloud auth application-default login` in the gcloud configuration
+// directory, or the service account of the metadata server when running on
+// Google Cloud. Tokens are requested with client.
+func googleTokenSource(client *http.Client) (tokenSource, error) {
+	// Tokens are requested for the lifetime of the provider, so they must not
+	// be bound to the context of configuring it
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+	credentials, err := google.FindDefaultCredentials(ctx, googleScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find google application default credentials: %w", err)
+	}
+
+	return func(context.Context) (string, time.Time, error) {
+		token, err := credentials.TokenSource.Token()
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("failed to get google access token: %w", err)
+		}
+		return token.AccessToken, token.Expiry, nil
+	}, nil
+}
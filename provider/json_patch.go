@@ -0,0 +1,571 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/util"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// The types of JSON patches.
+const (
+	// jsonPatchTypeJSON is a JSON Patch, as described in RFC 6902.
+	jsonPatchTypeJSON = "json"
+	// jsonPatchTypeMerge is a JSON Merge Patch, as described in RFC 7386.
+	jsonPatchTypeMerge = "merge"
+)
+
+func resourceCommitJSONPatch() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"path": {
+				Description:  "The path of the JSON file to patch. The file must exist.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateRepoPath,
+			},
+			"patch": {
+				Description:  "The patch, e.g. `jsonencode([{ op = \"replace\", path = \"/spec/source/targetRevision\", value = \"v1.2.0\" }])`. As the patch is applied again when refreshing, operations such as appending to an array should be guarded by a `test` operation.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringIsJSON,
+			},
+			"type": {
+				Description:  "The type of the patch: `json` for a JSON Patch (RFC 6902), or `merge` for a JSON Merge Patch (RFC 7386), where null values remove keys.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      jsonPatchTypeJSON,
+				ValidateFunc: validation.StringInSlice([]string{jsonPatchTypeJSON, jsonPatchTypeMerge}, false),
+			},
+		},
+	}
+}
+
+// jsonObject is a JSON object keeping the order of its keys, so patched files
+// only change where they are patched.
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *jsonObject) get(key string) (interface{}, bool) {
+	value, ok := o.values[key]
+	return value, ok
+}
+
+func (o *jsonObject) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *jsonObject) delete(key string) {
+	if _, ok := o.values[key]; !ok {
+		return
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// patchJSONFiles applies the patches of the json_patch items to the files of
+// worktree. Files whose data is unchanged by their patch are left untouched.
+func patchJSONFiles(worktree *gogit.Worktree, items []interface{}) diag.Diagnostics {
+	for _, item := range items {
+		path := worktree.Filesystem.Join(item.(map[string]interface{})["path"].(string))
+		patch := item.(map[string]interface{})["patch"].(string)
+		patchType := item.(map[string]interface{})["type"].(string)
+
+		content, err := util.ReadFile(worktree.Filesystem, path)
+		if err != nil {
+			return diag.Errorf("failed to read file %s: %s", path, err)
+		}
+
+		patched, err := patchJSON(content, []byte(patch), patchType)
+		if err != nil {
+			return diag.Errorf("failed to patch file %s: %s", path, err)
+		}
+		if bytes.Equal(patched, content) {
+			continue
+		}
+
+		info, err := worktree.Filesystem.Lstat(path)
+		if err != nil {
+			return diag.Errorf("failed to read file %s: %s", path, err)
+		}
+		if err := util.WriteFile(worktree.Filesystem, path, patched, info.Mode().Perm()); err != nil {
+			return diag.Errorf("failed to write file %s: %s", path, err)
+		}
+	}
+
+	return nil
+}
+
+// patchJSON returns content with patch of patchType applied. The keys of
+// objects keep their order and the indentation of content is kept, while
+// content is returned as is if its data is unchanged.
+func patchJSON(content, patch []byte, patchType string) ([]byte, error) {
+	document, err := decodeJSON(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse json: %w", err)
+	}
+	original, err := decodeJSON(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse json: %w", err)
+	}
+	operations, err := decodeJSON(patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse patch: %w", err)
+	}
+
+	switch patchType {
+	case jsonPatchTypeMerge:
+		document = mergePatch(document, operations)
+	default:
+		document, err = applyJSONPatch(document, operations)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if jsonEqual(document, original) {
+		return content, nil
+	}
+
+	return encodeJSON(document, content)
+}
+
+// applyJSONPatch applies the operations of a JSON Patch to document.
+func applyJSONPatch(document, operations interface{}) (interface{}, error) {
+	list, ok := operations.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("patch must be an array of operations")
+	}
+
+	for i, operation := range list {
+		object, ok := operation.(*jsonObject)
+		if !ok {
+			return nil, fmt.Errorf("operation %d must be an object", i)
+		}
+		field := func(key string) (string, error) {
+			value, ok := object.get(key)
+			if s, isString := value.(string); ok && isString {
+				return s, nil
+			}
+			return "", fmt.Errorf("operation %d must have a %s string", i, key)
+		}
+
+		op, err := field("op")
+		if err != nil {
+			return nil, err
+		}
+		path, err := field("path")
+		if err != nil {
+			return nil, err
+		}
+		value, hasValue := object.get("value")
+		if !hasValue && (op == "add" || op == "replace" || op == "test") {
+			return nil, fmt.Errorf("operation %d must have a value", i)
+		}
+
+		switch op {
+		case "add":
+			document, err = jsonPointerAdd(document, path, value)
+		case "remove":
+			document, _, err = jsonPointerRemove(document, path)
+		case "replace":
+			if _, err = jsonPointerGet(document, path); err == nil {
+				document, err = jsonPointerReplace(document, path, value)
+			}
+		case "move", "copy":
+			var from string
+			from, err = field("from")
+			if err != nil {
+				return nil, err
+			}
+			if op == "move" {
+				document, value, err = jsonPointerRemove(document, from)
+			} else if value, err = jsonPointerGet(document, from); err == nil {
+				value, err = decodeJSON(mustEncodeJSON(value))
+			}
+			if err == nil {
+				document, err = jsonPointerAdd(document, path, value)
+			}
+		case "test":
+			var current interface{}
+			current, err = jsonPointerGet(document, path)
+			if err == nil && !jsonEqual(current, value) {
+				err = fmt.Errorf("test of %s failed", path)
+			}
+		default:
+			err = fmt.Errorf("unknown op %s", op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+
+	return document, nil
+}
+
+// mergePatch applies a JSON Merge Patch to document.
+func mergePatch(document, patch interface{}) interface{} {
+	patchObject, ok := patch.(*jsonObject)
+	if !ok {
+		return patch
+	}
+	object, ok := document.(*jsonObject)
+	if !ok {
+		object = &jsonObject{values: make(map[string]interface{})}
+	}
+
+	for _, key := range patchObject.keys {
+		value := patchObject.values[key]
+		if value == nil {
+			object.delete(key)
+			continue
+		}
+		current, _ := object.get(key)
+		object.set(key, mergePatch(current, value))
+	}
+
+	return object
+}
+
+// splitJSONPointer returns the unescaped reference tokens of a JSON pointer,
+// as described in RFC 6901.
+func splitJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid json pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+// arrayIndex parses the token of an array of length n. The index n itself is
+// only valid when adding.
+func arrayIndex(token string, n int, adding bool) (int, error) {
+	if adding && token == "-" {
+		return n, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > n || (i == n && !adding) || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+
+	return i, nil
+}
+
+// jsonPointerGet returns the value of document at pointer.
+func jsonPointerGet(document interface{}, pointer string) (interface{}, error) {
+	tokens, err := splitJSONPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	value := document
+	for _, token := range tokens {
+		switch container := value.(type) {
+		case *jsonObject:
+			var ok bool
+			if value, ok = container.get(token); !ok {
+				return nil, fmt.Errorf("%s does not exist", pointer)
+			}
+		case []interface{}:
+			i, err := arrayIndex(token, len(container), false)
+			if err != nil {
+				return nil, err
+			}
+			value = container[i]
+		default:
+			return nil, fmt.Errorf("%s does not exist", pointer)
+		}
+	}
+
+	return value, nil
+}
+
+// jsonPointerAdd returns document with value added at pointer, replacing
+// the value of an existing key and inserting into arrays.
+func jsonPointerAdd(document interface{}, pointer string, value interface{}) (interface{}, error) {
+	tokens, err := splitJSONPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := jsonPointerGet(document, parentPointer)
+	if err != nil {
+		return nil, err
+	}
+
+	token := tokens[len(tokens)-1]
+	switch container := parent.(type) {
+	case *jsonObject:
+		container.set(token, value)
+		return document, nil
+	case []interface{}:
+		i, err := arrayIndex(token, len(container), true)
+		if err != nil {
+			return nil, err
+		}
+		container = append(container[:i], append([]interface{}{value}, container[i:]...)...)
+		return jsonPointerReplace(document, parentPointer, container)
+	default:
+		return nil, fmt.Errorf("%s is not an object or an array", parentPointer)
+	}
+}
+
+// jsonPointerRemove returns document with the value at pointer removed, and
+// the removed value.
+func jsonPointerRemove(document interface{}, pointer string) (interface{}, interface{}, error) {
+	tokens, err := splitJSONPointer(pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, document, nil
+	}
+
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := jsonPointerGet(document, parentPointer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token := tokens[len(tokens)-1]
+	switch container := parent.(type) {
+	case *jsonObject:
+		value, ok := container.get(token)
+		if !ok {
+			return nil, nil, fmt.Errorf("%s does not exist", pointer)
+		}
+		container.delete(token)
+		return document, value, nil
+	case []interface{}:
+		i, err := arrayIndex(token, len(container), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := container[i]
+		container = append(container[:i:i], container[i+1:]...)
+		document, err = jsonPointerReplace(document, parentPointer, container)
+		return document, value, err
+	default:
+		return nil, nil, fmt.Errorf("%s does not exist", pointer)
+	}
+}
+
+// jsonPointerReplace returns document with the value at the existing pointer
+// replaced by value. Arrays are replaced as appending to them can allocate a
+// new one.
+func jsonPointerReplace(document interface{}, pointer string, value interface{}) (interface{}, error) {
+	tokens, err := splitJSONPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	parent, err := jsonPointerGet(document, pointer[:strings.LastIndex(pointer, "/")])
+	if err != nil {
+		return nil, err
+	}
+	token := tokens[len(tokens)-1]
+	switch container := parent.(type) {
+	case *jsonObject:
+		container.set(token, value)
+	case []interface{}:
+		i, err := arrayIndex(token, len(container), false)
+		if err != nil {
+			return nil, err
+		}
+		container[i] = value
+	}
+
+	return document, nil
+}
+
+// decodeJSON decodes content, keeping the order of the keys of objects and
+// the text of numbers. Objects with duplicate keys are refused.
+func decodeJSON(content []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	value, err := decodeJSONValue(decoder)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unexpected content after the value")
+	}
+
+	return value, nil
+}
+
+func decodeJSONValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		object := &jsonObject{values: make(map[string]interface{})}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			// Duplicate keys are ambiguous, e.g. the op of a patch operation
+			if _, ok := object.get(key.(string)); ok {
+				return nil, fmt.Errorf("duplicate key %q", key)
+			}
+			object.set(key.(string), value)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return object, nil
+	case json.Delim('['):
+		array := []interface{}{}
+		for decoder.More() {
+			value, err := decodeJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		return array, nil
+	default:
+		return token, nil
+	}
+}
+
+// encodeJSON encodes value with the indentation and final newline of
+// original, the content it was decoded from.
+func encodeJSON(value interface{}, original []byte) ([]byte, error) {
+	compact := mustEncodeJSON(value)
+
+	indent := ""
+	for _, line := range bytes.Split(original, []byte("\n"))[1:] {
+		if trimmed := bytes.TrimLeft(line, " \t"); len(trimmed) > 0 && len(trimmed) < len(line) {
+			indent = string(line[:len(line)-len(trimmed)])
+			break
+		}
+	}
+	if indent == "" && bytes.Count(bytes.TrimSpace(original), []byte("\n")) == 0 {
+		if bytes.HasSuffix(original, []byte("\n")) {
+			compact = append(compact, '\n')
+		}
+		return compact, nil
+	}
+	if indent == "" {
+		indent = "  "
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, compact, "", indent); err != nil {
+		return nil, err
+	}
+	if bytes.HasSuffix(original, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
+
+// mustEncodeJSON encodes a decoded value compactly. As decoded values only
+// hold strings, numbers, booleans, nil, arrays and objects, it cannot fail.
+func mustEncodeJSON(value interface{}) []byte {
+	var buf bytes.Buffer
+	switch value := value.(type) {
+	case *jsonObject:
+		buf.WriteByte('{')
+		for i, key := range value.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(mustEncodeJSON(key))
+			buf.WriteByte(':')
+			buf.Write(mustEncodeJSON(value.values[key]))
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range value {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(mustEncodeJSON(item))
+		}
+		buf.WriteByte(']')
+	default:
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		_ = encoder.Encode(value)
+		buf.Truncate(buf.Len() - 1)
+	}
+
+	return buf.Bytes()
+}
+
+// jsonEqual reports whether the decoded values a and b hold the same data,
+// regardless of the order of the keys of objects.
+func jsonEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(plainJSON(a), plainJSON(b))
+}
+
+// plainJSON converts the objects of a decoded value to maps.
+func plainJSON(value interface{}) interface{} {
+	switch value := value.(type) {
+	case *jsonObject:
+		object := make(map[string]interface{}, len(value.keys))
+		for key, item := range value.values {
+			object[key] = plainJSON(item)
+		}
+		return object
+	case []interface{}:
+		array := make([]interface{}, len(value))
+		for i, item := range value {
+			array[i] = plainJSON(item)
+		}
+		return array
+	default:
+		return value
+	}
+}
@@ -0,0 +1,194 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/go-git/go-billy/v5/util"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"gopkg.in/yaml.v3"
+)
+
+func resourceCommitKustomizeImage() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"path": {
+				Description:  "The path of the kustomization file, e.g. `overlays/prod/kustomization.yaml`. The file must exist.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateRepoPath,
+			},
+			"name": {
+				Description:  "The name of the image to override, as used by the resources, e.g. `nginx`.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"new_name": {
+				Description: "The image replacing it, e.g. `registry.example.com/nginx`.",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"new_tag": {
+				Description: "The tag of the image. Setting it without `new_digest` removes the digest of the entry.",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"new_digest": {
+				Description: "The digest of the image, e.g. `sha256:...`. Setting it without `new_tag` removes the tag of the entry.",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+		},
+	}
+}
+
+// setKustomizeImages overrides the images of the kustomize_image items in the
+// images of their kustomization file, adding the entries that are missing.
+// Files whose entries are already set are left untouched.
+func setKustomizeImages(worktree *gogit.Worktree, items []interface{}) diag.Diagnostics {
+	for _, item := range items {
+		path := worktree.Filesystem.Join(item.(map[string]interface{})["path"].(string))
+		name := item.(map[string]interface{})["name"].(string)
+
+		fields := make(map[string]string)
+		for key, field := range map[string]string{"new_name": "newName", "new_tag": "newTag", "new_digest": "newDigest"} {
+			fields[field] = item.(map[string]interface{})[key].(string)
+		}
+		if fields["newName"] == "" && fields["newTag"] == "" && fields["newDigest"] == "" {
+			return diag.Errorf("failed to override image %s in file %s: set one of new_name, new_tag and new_digest", name, path)
+		}
+
+		content, err := util.ReadFile(worktree.Filesystem, path)
+		if err != nil {
+			return diag.Errorf("failed to read file %s: %s", path, err)
+		}
+
+		edited, err := setKustomizeImage(content, name, fields)
+		if err != nil {
+			return diag.Errorf("failed to override image %s in file %s: %s", name, path, err)
+		}
+		if bytes.Equal(edited, content) {
+			continue
+		}
+
+		info, err := worktree.Filesystem.Lstat(path)
+		if err != nil {
+			return diag.Errorf("failed to read file %s: %s", path, err)
+		}
+		if err := util.WriteFile(worktree.Filesystem, path, edited, info.Mode().Perm()); err != nil {
+			return diag.Errorf("failed to write file %s: %s", path, err)
+		}
+	}
+
+	return nil
+}
+
+// setKustomizeImage returns the kustomization content with the fields of the
+// entry of the image name set. A tag without a digest removes the digest of
+// the entry, and the other way around, as kustomize ignores the tag of images
+// with a digest.
+func setKustomizeImage(content []byte, name string, fields map[string]string) ([]byte, error) {
+	documents, err := decodeYAMLNodes(content)
+	if err != nil {
+		return nil, err
+	}
+	if len(documents) == 0 {
+		documents = append(documents, &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}})
+	}
+	root := documents[0].Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("kustomization is not a mapping")
+	}
+
+	changed := false
+	images := yamlMappingValue(root, "images")
+	if images != nil && images.Kind == yaml.ScalarNode && images.Tag == "!!null" {
+		*images = yaml.Node{Kind: yaml.SequenceNode}
+		changed = true
+	} else if images == nil {
+		images = &yaml.Node{Kind: yaml.SequenceNode}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "images"}, images)
+		changed = true
+	}
+	if images.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("images is not a sequence")
+	}
+
+	var entry *yaml.Node
+	for _, image := range images.Content {
+		if value := yamlMappingValue(image, "name"); value != nil && value.Value == name {
+			entry = image
+			break
+		}
+	}
+	if entry == nil {
+		entry = &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: "name"},
+			yamlString(name),
+		}}
+		images.Content = append(images.Content, entry)
+		changed = true
+	}
+
+	if fields["newTag"] != "" && fields["newDigest"] == "" {
+		changed = deleteYAMLMappingKey(entry, "newDigest") || changed
+	}
+	if fields["newDigest"] != "" && fields["newTag"] == "" {
+		changed = deleteYAMLMappingKey(entry, "newTag") || changed
+	}
+	for _, field := range []string{"newName", "newTag", "newDigest"} {
+		if fields[field] == "" {
+			continue
+		}
+		value := yamlMappingValue(entry, field)
+		if value != nil && value.Kind == yaml.ScalarNode && value.Value == fields[field] && value.Tag == "!!str" {
+			continue
+		}
+
+		// Tags such as 1.10 are strings rather than numbers
+		replacement := yamlString(fields[field])
+		if value == nil {
+			entry.Content = append(entry.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: field}, replacement)
+		} else {
+			replacement.LineComment = value.LineComment
+			*value = *replacement
+		}
+		changed = true
+	}
+	if !changed {
+		return content, nil
+	}
+
+	return encodeYAMLNodes(documents)
+}
+
+// yamlMappingValue returns the value of key in the mapping node, or nil.
+func yamlMappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// deleteYAMLMappingKey removes key from the mapping node, and reports whether
+// it was there.
+func deleteYAMLMappingKey(node *yaml.Node, key string) bool {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,55 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// localLoader loads the repositories of file:// URLs and absolute paths,
+// either bare or with a worktree.
+type localLoader struct{}
+
+// Load implements server.Loader.
+func (localLoader) Load(ep *transport.Endpoint) (storer.Storer, error) {
+	path := filepath.FromSlash(ep.Path)
+	if len(path) > 2 && os.IsPathSeparator(path[0]) && hasDriveLetter(path[1:]) {
+		// file:///C:/repo has the path /C:/repo
+		path = path[1:]
+	}
+
+	for _, dir := range []string{path, filepath.Join(path, ".git")} {
+		if _, err := os.Stat(filepath.Join(dir, "config")); err == nil {
+			return filesystem.NewStorage(osfs.New(dir), cache.NewObjectLRUDefault()), nil
+		}
+	}
+
+	return nil, transport.ErrRepositoryNotFound
+}
+
+// installLocalTransportOnce installs the file transport once per process.
+var installLocalTransportOnce sync.Once
+
+// installLocalTransport serves local repositories in-process rather than by
+// running git-upload-pack and git-receive-pack, so git does not have to be
+// installed.
+func installLocalTransport() {
+	installLocalTransportOnce.Do(func() {
+		client.InstallProtocol("file", server.NewServer(localLoader{}))
+	})
+}
+
+// isLocalPath reports whether a repository URL is an absolute path rather
+// than a URL.
+func isLocalPath(rawURL string) bool {
+	return filepath.IsAbs(rawURL) || strings.HasPrefix(rawURL, "/") || hasDriveLetter(rawURL)
+}
@@ -0,0 +1,242 @@
+package provider
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// resolveRef resolves a branch, tag or commit sha of a cloned repository,
+// preferring the branch of the same name of the remote remoteName.
+func resolveRef(repo *gogit.Repository, remoteName, ref string) (*plumbing.Hash, error) {
+	sha, err := repo.ResolveRevision(plumbing.Revision(fmt.Sprintf("%s/%s", remoteName, ref)))
+	if err != nil && errors.Is(err, plumbing.ErrReferenceNotFound) {
+		sha, err = repo.ResolveRevision(plumbing.Revision(ref))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve ref %s: %w", ref, err)
+	}
+
+	return sha, nil
+}
+
+// hunk is a change to the lines [start, end) of a file, replacing them with
+// text.
+type hunk struct {
+	start int
+	end   int
+	text  string
+}
+
+// mergeConflicts returns the paths that conflict when merging the changes
+// made from base to ours and from base to theirs, the same way a three-way
+// merge does: a file conflicts when both sides change overlapping or adjacent
+// lines differently, or when one side deletes a file the other changes.
+func mergeConflicts(base, ours, theirs *object.Tree) ([]string, error) {
+	oursChanged, err := changedPaths(base, ours)
+	if err != nil {
+		return nil, err
+	}
+	theirsChanged, err := changedPaths(base, theirs)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []string
+	for path := range oursChanged {
+		if !theirsChanged[path] {
+			continue
+		}
+
+		baseContent, err := treeFileContent(base, path)
+		if err != nil {
+			return nil, err
+		}
+		oursContent, err := treeFileContent(ours, path)
+		if err != nil {
+			return nil, err
+		}
+		theirsContent, err := treeFileContent(theirs, path)
+		if err != nil {
+			return nil, err
+		}
+
+		if contentConflicts(baseContent, oursContent, theirsContent) {
+			conflicts = append(conflicts, path)
+		}
+	}
+	sort.Strings(conflicts)
+
+	return conflicts, nil
+}
+
+// contentConflicts reports whether the changes of both sides to a file
+// conflict. A nil content means the file does not exist.
+func contentConflicts(base, ours, theirs []byte) bool {
+	switch {
+	case bytes.Equal(ours, theirs) && (ours == nil) == (theirs == nil):
+		// Both sides made the same change
+		return false
+	case ours == nil || theirs == nil:
+		// Deleted on one side, changed on the other
+		return true
+	case isBinary(base) || isBinary(ours) || isBinary(theirs):
+		return true
+	}
+
+	oursHunks := changedHunks(string(base), string(ours))
+	theirsHunks := changedHunks(string(base), string(theirs))
+	for _, a := range oursHunks {
+		for _, b := range theirsHunks {
+			if a.start <= b.end && b.start <= a.end && a != b {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// mergeContent merges the changes made to a file from base to ours and from
+// base to theirs, reporting false if they conflict. A nil content means the
+// file does not exist.
+func mergeContent(base, ours, theirs []byte) ([]byte, bool) {
+	if contentConflicts(base, ours, theirs) {
+		return nil, false
+	}
+	if bytes.Equal(ours, theirs) {
+		return ours, true
+	}
+
+	hunks := changedHunks(string(base), string(ours))
+	for _, h := range changedHunks(string(base), string(theirs)) {
+		duplicate := false
+		for _, other := range hunks {
+			duplicate = duplicate || h == other
+		}
+		if !duplicate {
+			hunks = append(hunks, h)
+		}
+	}
+	sort.Slice(hunks, func(i, j int) bool {
+		return hunks[i].start < hunks[j].start
+	})
+
+	// The hunks do not overlap, as they would conflict otherwise
+	lines := strings.SplitAfter(string(base), "\n")
+	var merged strings.Builder
+	line := 0
+	for _, h := range hunks {
+		merged.WriteString(strings.Join(lines[line:h.start], ""))
+		merged.WriteString(h.text)
+		line = h.end
+	}
+	merged.WriteString(strings.Join(lines[line:], ""))
+
+	return []byte(merged.String()), true
+}
+
+// changedHunks returns the hunks changing the lines of base into other.
+func changedHunks(base, other string) []hunk {
+	var hunks []hunk
+	var current *hunk
+	line := 0
+
+	for _, d := range diff.Do(base, other) {
+		count := strings.Count(d.Text, "\n")
+		if !strings.HasSuffix(d.Text, "\n") {
+			count++
+		}
+
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			if current != nil {
+				hunks = append(hunks, *current)
+				current = nil
+			}
+			line += count
+		case diffmatchpatch.DiffDelete:
+			if current == nil {
+				current = &hunk{start: line, end: line}
+			}
+			line += count
+			current.end = line
+		case diffmatchpatch.DiffInsert:
+			if current == nil {
+				current = &hunk{start: line, end: line}
+			}
+			current.text += d.Text
+		}
+	}
+	if current != nil {
+		hunks = append(hunks, *current)
+	}
+
+	return hunks
+}
+
+// changedPaths returns the paths of the files that differ between two trees.
+func changedPaths(from, to *object.Tree) (map[string]bool, error) {
+	changes, err := object.DiffTree(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff trees: %w", err)
+	}
+
+	paths := make(map[string]bool)
+	for _, change := range changes {
+		if change.From.Name != "" {
+			paths[change.From.Name] = true
+		}
+		if change.To.Name != "" {
+			paths[change.To.Name] = true
+		}
+	}
+
+	return paths, nil
+}
+
+// treeFileContent returns the content of a file in a tree, or nil if it does
+// not exist.
+func treeFileContent(tree *object.Tree, path string) ([]byte, error) {
+	file, err := tree.File(path)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get file %s: %w", path, err)
+	}
+
+	reader, err := file.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	if content == nil {
+		content = []byte{}
+	}
+
+	return content, nil
+}
+
+// isBinary reports whether content looks binary, using the same heuristic as
+// git: a NUL byte in the first 8000 bytes.
+func isBinary(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+
+	return bytes.IndexByte(content, 0) >= 0
+}
@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// netrcEntry is a machine or default entry of a .netrc file.
+type netrcEntry struct {
+	machine  string
+	login    string
+	password string
+}
+
+// defaultNetrcPath returns the path of the .netrc file curl and git read:
+// NETRC if set, otherwise .netrc in the home directory, or _netrc on Windows.
+func defaultNetrcPath() (string, error) {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	name := ".netrc"
+	if runtime.GOOS == "windows" {
+		name = "_netrc"
+	}
+
+	return filepath.Join(home, name), nil
+}
+
+// readNetrc returns the entries of a .netrc file. The default entry, if any,
+// has an empty machine. Macro definitions are skipped.
+func readNetrc(path string) ([]netrcEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read netrc file: %w", err)
+	}
+
+	var entries []netrcEntry
+	var entry *netrcEntry
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if j := strings.Index(line, "#"); j >= 0 {
+			line = line[:j]
+		}
+
+		fields := strings.Fields(line)
+		for j := 0; j < len(fields); j++ {
+			var value string
+			if j+1 < len(fields) {
+				value = fields[j+1]
+			}
+
+			switch fields[j] {
+			case "machine":
+				entries = append(entries, netrcEntry{machine: value})
+				entry = &entries[len(entries)-1]
+				j++
+			case "default":
+				entries = append(entries, netrcEntry{})
+				entry = &entries[len(entries)-1]
+			case "login":
+				if entry != nil {
+					entry.login = value
+				}
+				j++
+			case "password":
+				if entry != nil {
+					entry.password = value
+				}
+				j++
+			case "account":
+				j++
+			case "macdef":
+				// A macro runs until the next empty line
+				for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+					i++
+				}
+				j = len(fields)
+			}
+		}
+	}
+
+	return entries, nil
+}
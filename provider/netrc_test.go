@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadNetrc(t *testing.T) {
+	content := `# git servers
+machine github.com login octocat password gh-token
+machine gitlab.example.com
+  login ci
+  password gl-token # deploy token
+  account ignored
+
+macdef init
+machine ignored.example.com login macro password macro
+
+default login anonymous password default-token
+`
+	path := filepath.Join(t.TempDir(), ".netrc")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := readNetrc(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []netrcEntry{
+		{machine: "github.com", login: "octocat", password: "gh-token"},
+		{machine: "gitlab.example.com", login: "ci", password: "gl-token"},
+		{login: "anonymous", password: "default-token"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("readNetrc() = %+v, want %+v", entries, want)
+	}
+}
@@ -0,0 +1,59 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// oauth2ClientCredentials returns a source of access tokens requested with
+// client from an OAuth2 token endpoint using the client credentials grant.
+func oauth2ClientCredentials(client *http.Client, tokenURL, clientID, clientSecret string, scopes []string) tokenSource {
+	return func(ctx context.Context) (string, time.Time, error) {
+		form := url.Values{
+			"grant_type": {"client_credentials"},
+		}
+		if len(scopes) > 0 {
+			form.Set("scope", strings.Join(scopes, " "))
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("failed to get oauth2 access token: %w", err)
+		}
+		defer resp.Body.Close()
+
+		var body struct {
+			AccessToken      string `json:"access_token"`
+			ExpiresIn        int    `json:"expires_in"`
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return "", time.Time{}, fmt.Errorf("failed to decode oauth2 access token: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK || body.AccessToken == "" {
+			return "", time.Time{}, fmt.Errorf("failed to get oauth2 access token: %s: %s %s", resp.Status, body.Error, body.ErrorDescription)
+		}
+
+		// Tokens without an expiry are kept for the lifetime of the provider
+		var expiry time.Time
+		if body.ExpiresIn > 0 {
+			expiry = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+		}
+
+		return body.AccessToken, expiry, nil
+	}
+}
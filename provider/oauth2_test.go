@@ -0,0 +1,36 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	// The server's certificate is only trusted by its own client
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "read write" || id != "client" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "invalid_client"}`)) //nolint:errcheck
+			return
+		}
+		w.Write([]byte(`{"access_token": "access-token", "expires_in": 60}`)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	source := oauth2ClientCredentials(server.Client(), server.URL, "client", "secret", []string{"read", "write"})
+	token, expiry, err := source(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "access-token" || expiry.IsZero() {
+		t.Errorf("source() = %s, %s, want access-token with an expiry", token, expiry)
+	}
+
+	source = oauth2ClientCredentials(server.Client(), server.URL, "client", "wrong", nil)
+	if _, _, err := source(context.Background()); err == nil {
+		t.Error("source() succeeded with invalid credentials")
+	}
+}
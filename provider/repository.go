@@ -0,0 +1,393 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// bundleSignature is the first line of a version 2 git bundle.
+const bundleSignature = "# v2 git bundle"
+
+// clone clones the repository at url into memory as the remote remoteName,
+// checking out the default branch into fs if it is not nil. Local bundle
+// files, given as a file:// URL, are loaded rather than cloned.
+func (c *providerConfig) clone(ctx context.Context, rawURL, remoteName string, fs billy.Filesystem) (*gogit.Repository, error) {
+	rawURL = c.rewriteURL(rawURL)
+
+	if path, ok := bundlePath(rawURL); ok {
+		return cloneBundle(rawURL, remoteName, path, fs)
+	}
+
+	rawURL, auth, err := c.resolveRemote(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &gogit.CloneOptions{
+		URL:        rawURL,
+		RemoteName: remoteName,
+		Auth:       auth,
+	}
+	repo, err := gogit.CloneContext(ctx, memory.NewStorage(), fs, opts)
+	if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return repo, err
+	}
+
+	// HEAD of the remote points to a branch that does not exist, e.g. when the
+	// first commit of an empty repository was pushed to another branch, so
+	// another branch is checked out instead
+	opts.ReferenceName, err = anyBranch(ctx, rawURL, remoteName, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	return gogit.CloneContext(ctx, memory.NewStorage(), fs, opts)
+}
+
+// remoteRefs returns the commits the refs of the repository at rawURL, the
+// remote remoteName, point to, by name.
+func remoteRefs(ctx context.Context, rawURL, remoteName string, auth transport.AuthMethod) (map[plumbing.ReferenceName]plumbing.Hash, error) {
+	remote := gogit.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: remoteName,
+		URLs: []string{rawURL},
+	})
+
+	refs, err := remote.ListContext(ctx, &gogit.ListOptions{
+		Auth: auth,
+	})
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[plumbing.ReferenceName]plumbing.Hash)
+	for _, ref := range refs {
+		hashes[ref.Name()] = ref.Hash()
+	}
+
+	return hashes, nil
+}
+
+// anyBranch returns the first branch of the repository at rawURL, the remote
+// remoteName.
+func anyBranch(ctx context.Context, rawURL, remoteName string, auth transport.AuthMethod) (plumbing.ReferenceName, error) {
+	remote := gogit.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: remoteName,
+		URLs: []string{rawURL},
+	})
+
+	refs, err := remote.ListContext(ctx, &gogit.ListOptions{
+		Auth: auth,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var branches []string
+	for _, ref := range refs {
+		if ref.Name().IsBranch() {
+			branches = append(branches, ref.Name().String())
+		}
+	}
+	if len(branches) == 0 {
+		return "", fmt.Errorf("repository has no branches")
+	}
+	sort.Strings(branches)
+
+	return plumbing.ReferenceName(branches[0]), nil
+}
+
+// cloneOrInit clones the repository at rawURL like clone. As empty
+// repositories cannot be cloned, an empty repository is initialized instead,
+// with the remote remoteName pointing to the remote one so that its first
+// commit can be pushed.
+func (c *providerConfig) cloneOrInit(ctx context.Context, rawURL, remoteName string, fs billy.Filesystem) (*gogit.Repository, error) {
+	repo, err := c.clone(ctx, rawURL, remoteName, fs)
+	if !errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return repo, err
+	}
+
+	rawURL, _, err = c.resolveRemote(ctx, c.rewriteURL(rawURL))
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err = gogit.Init(memory.NewStorage(), fs)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{
+		Name: remoteName,
+		URLs: []string{rawURL},
+	}); err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+// isEmptyRepository returns whether repo has no commits, i.e. has no refs
+// but HEAD.
+func isEmptyRepository(repo *gogit.Repository) (bool, error) {
+	refs, err := repo.References()
+	if err != nil {
+		return false, err
+	}
+	defer refs.Close()
+
+	empty := true
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Name() != plumbing.HEAD {
+			empty = false
+			return storer.ErrStop
+		}
+		return nil
+	})
+
+	return empty, err
+}
+
+// checkoutCommit force checks out the commit sha into worktree. The worktree
+// is emptied first, as go-git fails to remove the checked out files that the
+// commit does not have, e.g. when switching to an orphan branch.
+func checkoutCommit(worktree *gogit.Worktree, sha plumbing.Hash) error {
+	if err := emptyWorktree(worktree); err != nil {
+		return err
+	}
+
+	return worktree.Checkout(&gogit.CheckoutOptions{
+		Hash:  sha,
+		Force: true,
+	})
+}
+
+// emptyWorktree removes all the files of worktree.
+func emptyWorktree(worktree *gogit.Worktree) error {
+	entries, err := worktree.Filesystem.ReadDir("/")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := util.RemoveAll(worktree.Filesystem, entry.Name()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkAuth lists the refs of the repository at rawURL to check the
+// credentials for it work.
+func (c *providerConfig) checkAuth(ctx context.Context, rawURL string) error {
+	rawURL, auth, err := c.resolveRemote(ctx, c.rewriteURL(rawURL))
+	if err != nil {
+		return err
+	}
+
+	return listRemote(ctx, rawURL, gogit.DefaultRemoteName, auth)
+}
+
+// listRemote lists the refs of the repository at rawURL, the remote
+// remoteName.
+func listRemote(ctx context.Context, rawURL, remoteName string, auth transport.AuthMethod) error {
+	remote := gogit.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: remoteName,
+		URLs: []string{rawURL},
+	})
+
+	_, err := remote.ListContext(ctx, &gogit.ListOptions{
+		Auth: auth,
+	})
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return nil
+	}
+
+	return err
+}
+
+// listRefs lists the refs of the remote remoteName of repo. The refs of a
+// bundle are listed from the repository itself, as bundles cannot be listed
+// remotely.
+func (c *providerConfig) listRefs(ctx context.Context, repo *gogit.Repository, remoteName string) ([]*plumbing.Reference, error) {
+	remote, err := repo.Remote(remoteName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve remote: %w", err)
+	}
+
+	if _, ok := bundlePath(remote.Config().URLs[0]); !ok {
+		_, auth, err := c.resolveRemote(ctx, remote.Config().URLs[0])
+		if err != nil {
+			return nil, err
+		}
+
+		return remote.ListContext(ctx, &gogit.ListOptions{
+			Auth: auth,
+		})
+	}
+
+	iter, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []*plumbing.Reference
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		switch {
+		case ref.Name().IsRemote():
+			name := plumbing.NewBranchReferenceName(strings.TrimPrefix(ref.Name().Short(), remoteName+"/"))
+			refs = append(refs, plumbing.NewHashReference(name, ref.Hash()))
+		case ref.Name().IsTag():
+			refs = append(refs, ref)
+		}
+		return nil
+	})
+
+	return refs, err
+}
+
+// bundlePath returns the local path of a bundle file given as a file:// URL or
+// an absolute path.
+func bundlePath(rawURL string) (string, bool) {
+	if isLocalPath(rawURL) {
+		return rawURL, strings.HasSuffix(rawURL, ".bundle")
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "file" || !strings.HasSuffix(u.Path, ".bundle") {
+		return "", false
+	}
+
+	return u.Path, true
+}
+
+// cloneBundle loads a bundle file into memory as if it was cloned from the
+// remote remoteName with the given URL. The branches of the bundle become
+// remote branches, and the branch HEAD points to is checked out into fs.
+func cloneBundle(rawURL, remoteName, path string, fs billy.Filesystem) (*gogit.Repository, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	refs, err := readBundleHeader(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle %s: %w", path, err)
+	}
+
+	repo, err := gogit.Init(memory.NewStorage(), fs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize repository: %w", err)
+	}
+
+	if err := packfile.UpdateObjectStorage(repo.Storer, reader); err != nil {
+		return nil, fmt.Errorf("failed to read bundle %s: %w", path, err)
+	}
+
+	_, err = repo.CreateRemote(&config.RemoteConfig{
+		Name: remoteName,
+		URLs: []string{rawURL},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create remote: %w", err)
+	}
+
+	// Store the refs the same way a clone does
+	var headBranch plumbing.ReferenceName
+	for name, hash := range refs {
+		switch {
+		case name == plumbing.HEAD:
+			continue
+		case name.IsBranch():
+			name = plumbing.NewRemoteReferenceName(remoteName, name.Short())
+			if hash == refs[plumbing.HEAD] && (headBranch == "" || name.Short() == remoteName+"/main") {
+				headBranch = name
+			}
+		}
+
+		if err := repo.Storer.SetReference(plumbing.NewHashReference(name, hash)); err != nil {
+			return nil, fmt.Errorf("failed to set ref %s: %w", name, err)
+		}
+	}
+
+	if headBranch == "" {
+		return repo, nil
+	}
+
+	// Check out the branch HEAD points to
+	branch := plumbing.NewBranchReferenceName(strings.TrimPrefix(headBranch.Short(), remoteName+"/"))
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(branch, refs[plumbing.HEAD])); err != nil {
+		return nil, fmt.Errorf("failed to set ref %s: %w", branch, err)
+	}
+	if err := repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branch)); err != nil {
+		return nil, fmt.Errorf("failed to set HEAD: %w", err)
+	}
+
+	if fs != nil {
+		worktree, err := repo.Worktree()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get worktree: %w", err)
+		}
+		if err := worktree.Reset(&gogit.ResetOptions{Mode: gogit.HardReset}); err != nil {
+			return nil, fmt.Errorf("failed to checkout %s: %w", branch, err)
+		}
+	}
+
+	return repo, nil
+}
+
+// readBundleHeader reads the header of a bundle, returning its refs. Bundles
+// with prerequisites cannot be loaded on their own, so they are rejected.
+func readBundleHeader(reader *bufio.Reader) (map[plumbing.ReferenceName]plumbing.Hash, error) {
+	signature, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(signature, "\n") != bundleSignature {
+		return nil, fmt.Errorf("not a v2 git bundle")
+	}
+
+	refs := make(map[plumbing.ReferenceName]plumbing.Hash)
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return nil, fmt.Errorf("unexpected end of bundle header")
+		} else if err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return refs, nil
+		}
+
+		if strings.HasPrefix(line, "-") {
+			return nil, fmt.Errorf("bundles with prerequisites are not supported")
+		}
+
+		hash, name, ok := strings.Cut(line, " ")
+		if !ok || !plumbing.IsHash(hash) {
+			return nil, fmt.Errorf("invalid ref line %q", line)
+		}
+		refs[plumbing.ReferenceName(name)] = plumbing.NewHash(hash)
+	}
+}
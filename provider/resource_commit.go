@@ -1,18 +1,33 @@
 package provider
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha1"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
-
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
 	gogit "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/format/index"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
-	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
@@ -21,24 +36,76 @@ import (
 func resourceCommit() *schema.Resource {
 	return &schema.Resource{
 		Description:   "A resource to create a git commit with one or more files or removals.",
-		CreateContext: resourceCommitCreate,
-		ReadContext:   resourceCommitRead,
-		UpdateContext: resourceCommitUpdate,
-		DeleteContext: resourceCommitDelete,
+		CreateContext: redacted(resourceCommitCreate),
+		ReadContext:   redacted(resourceCommitRead),
+		UpdateContext: redacted(resourceCommitUpdate),
+		DeleteContext: redacted(resourceCommitDelete),
+		CustomizeDiff: resourceCommitCustomizeDiff,
 
 		Schema: map[string]*schema.Schema{
 			"url": {
 				Type:         schema.TypeString,
 				Required:     true,
-				ForceNew:     true,
-				ValidateFunc: validation.IsURLWithScheme([]string{"http", "https", "ssh"}),
-				Description:  "The URL of the git repository. Must be http, https, or ssh.",
+				ValidateFunc: validateRepoURL,
+				Description:  "The URL of the git repository. Must be an http, https or ssh URL, an scp-like SSH URL such as `git@github.com:org/repo.git`, or the file URL or absolute path of a local repository or bundle file. Commits pushed to the checked out branch of a local repository with a worktree do not update the worktree, so bare repositories are recommended. Changing to an equivalent URL for the same repository, e.g. from ssh to https or adding a `.git` suffix, does not replace the resource.",
+			},
+			"push_url": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateRemoteURL,
+				Description:  "The URL of the git repository to push the commit to, if different from `url`, e.g. to clone from a read-only mirror and push to the primary. The branch is fetched from it, so commits build on the branch they are pushed to even when `url` lags behind. Required when `url` is a bundle file.",
+			},
+			"remote_name": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringMatch(remoteNamePattern, "must be a valid remote name"),
+				Description:  "The name of the remote in the in-memory clone the commits are made in, and of its remote-tracking branches. Defaults to `origin`.",
 			},
 			"branch": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateBranchName,
+				Description:  "The git branch to commit to. In an empty repository, the first commit is created on this branch.",
+			},
+			"target_ref": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validateRefName,
+				Description:  "The ref to push the commit to instead of the branch, e.g. `refs/for/main` or `refs/heads/generated/config`. The commit builds on the tip of this ref if it exists, otherwise on the tip of the branch.",
+			},
+			"create_branch": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Create the branch from `base_ref` when it does not exist, instead of failing.",
+			},
+			"base_ref": {
 				Type:        schema.TypeString,
-				Required:    true,
-				ForceNew:    true,
-				Description: "The git branch to commit to.",
+				Optional:    true,
+				Description: "The branch, tag or commit sha new branches are created from when `create_branch` is set. Defaults to the default branch of the repository.",
+			},
+			"orphan": {
+				Type:          schema.TypeBool,
+				Optional:      true,
+				Default:       false,
+				ConflictsWith: []string{"create_branch", "base_ref"},
+				Description:   "Create the branch without history when it does not exist, e.g. for `gh-pages`: its first commit has no parent and only contains the files of the resource.",
+			},
+			"expected_parent_sha": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringMatch(shaPattern, "must be a full sha"),
+				Description:  "The sha the branch, or `target_ref`, must point to for the commit to be pushed, e.g. the one the plan was made against. The apply fails with the actual tip of the branch if it was updated since, instead of committing on top of changes that were not planned.",
+			},
+			"allowed_signers": {
+				Description: "ASCII armored PGP public keys. When set, the commit is only created if the commit it builds on is signed by one of these keys.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
 			},
 			"message": {
 				Type:        schema.TypeString,
@@ -56,32 +123,199 @@ func resourceCommit() *schema.Resource {
 				Optional:    true,
 				Description: "The commit message to use on delete.",
 			},
-			"add": {
-				Description: "A file to add. Contains a path and the file content.",
+			"split_commits": {
+				Description: "Commit the changes of each `add` and `remove` block on its own, with `split_message`, rather than all in one commit, e.g. for review tools and changelog generators working with commits of a single file. The other changes are committed last with the commit message, and all the commits are pushed together. Conflicts with `update_strategy = \"amend\"`.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"split_message": {
+				Description: "The message of the commits of the blocks when `split_commits` is set. `{message}` is replaced with the commit message, `{action}` with `add` or `remove`, and `{path}` with the path of the block.",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "{message}: {action} {path}",
+			},
+			"skip_ci": {
+				Description: "Add `[skip ci]` to the subject of commit messages so they do not trigger CI pipelines.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"push_options": {
+				Description: "Push options sent to the remote, like `git push -o`, e.g. `[\"ci.skip\", \"merge_request.create\", \"merge_request.target=main\"]` for GitLab or `[\"topic=deps\"]` for Gerrit. They are ignored by remotes that do not support push options.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringIsNotEmpty,
+				},
+			},
+			"tags": {
+				Description: "Tags created on the commit and pushed with it, e.g. for releases. Tags are created with the commit that is pushed when they are added, or with the existing commit when the files are unchanged, and are never moved or deleted afterwards.",
 				Type:        schema.TypeList,
 				Optional:    true,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
-						"path": {
-							Type:     schema.TypeString,
-							Required: true,
+						"name": {
+							Description:  "The name of the tag, e.g. `v1.2.3`.",
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringIsNotEmpty,
+						},
+						"message": {
+							Description: "The message of the tag, making it an annotated tag with the committer as tagger. Without a message, the tag is lightweight.",
+							Type:        schema.TypeString,
+							Optional:    true,
+						},
+					},
+				},
+			},
+			"add_change_id": {
+				Description: "Add a Gerrit `Change-Id` trailer to commit messages that have none, so commits pushed to `refs/for/<branch>` with `target_ref` create Gerrit changes. A new change is created with every commit; set the `Change-Id` in `trailers` to upload new patch sets of an existing change instead.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"update_strategy": {
+				Description:  "How updates are committed: `commit` adds a new commit on top of the branch, and `amend` replaces the commit of the resource with a new one containing all of its changes, with `message`, and force pushes it with a lease: the push is aborted if the branch changed since it was read. Updates fall back to `commit` when the commit of the resource is no longer the head of the branch, so commits pushed since are never lost, and when it is the root commit of the branch, which has no parent to amend it onto.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      updateStrategyCommit,
+				ValidateFunc: validation.StringInSlice([]string{updateStrategyCommit, updateStrategyAmend}, false),
+			},
+			"push_retries": {
+				Description:  "How many times the changes are applied again on top of the branch, from a new clone, when the push is rejected because the branch was updated meanwhile, e.g. by a concurrent pipeline. Defaults to `0`: the apply fails, so the changes are not silently applied on top of commits the plan did not show.",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"allow_empty": {
+				Description: "Create a commit even when the files are unchanged, e.g. to trigger CI pipelines or GitOps reconciliation. Otherwise the existing commit is used and nothing is pushed.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"trailers": {
+				Description: "Trailers added to the commit messages, e.g. `{ \"Signed-off-by\" = \"Jane Doe <jane@example.com>\", \"Ticket\" = \"OPS-123\" }`. They are added to the trailers of the provider, replacing the ones with the same key.",
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"co_authors": {
+				Description: "People the commits are made on behalf of, added as `Co-authored-by` trailers so that GitHub attributes the commits to them too.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Description: "The name of the co-author.",
+							Type:        schema.TypeString,
+							Required:    true,
 						},
-						"content": {
-							Type:     schema.TypeString,
-							Required: true,
+						"email": {
+							Description: "The email of the co-author. GitHub matches it against the emails of its users.",
+							Type:        schema.TypeString,
+							Required:    true,
 						},
 					},
 				},
 			},
+			"conventional_commits": {
+				Description: "Require commit messages to follow the Conventional Commits format, e.g. `feat(api): add endpoint`. Checked at plan time.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"message_pattern": {
+				Description:  "A regular expression commit messages must match. Checked at plan time.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringIsValidRegExp,
+			},
+			"add": {
+				Description: "A file to add. Contains a path and the file content. The order of add blocks is not significant.",
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem:        resourceCommitAdd(),
+				Set:         hashAddItem,
+			},
+			"source_dir": {
+				Description: "A local directory whose files are added, e.g. a rendered Helm chart. The files are read when applying, and added files are detected when refreshing, which replaces the resource. With `prune`, files of the repository matching its patterns that are not in the directory are removed.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        resourceCommitSourceDir(),
+			},
+			"yaml_set": {
+				Description: "A value to set in a YAML file of the repository, e.g. the image tag of a deployment, leaving the rest of the file as it is. Values are set after `add` and `source_dir` are written.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        resourceCommitYAMLSet(),
+			},
+			"json_patch": {
+				Description: "A patch to apply to a JSON file of the repository, leaving the rest of the file as it is. Patches are applied after `yaml_set`.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        resourceCommitJSONPatch(),
+			},
+			"kustomize_image": {
+				Description: "An image to override in the `images` of a kustomization file, e.g. to deploy a new tag. The entry of the image is added if it is missing. Images are overridden after `json_patch` is applied.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        resourceCommitKustomizeImage(),
+			},
+			"validation": {
+				Description: "Checks the added files must pass before they are committed.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Elem:        resourceCommitValidation(),
+			},
+			"stage_managed_only": {
+				Description: "Only stage the paths of `add`, `source_dir`, `move`, `yaml_set`, `json_patch`, `kustomize_image` and `remove`, rather than every change of the worktree, so files written by anything else can never be committed.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"respect_gitignore": {
+				Description: "Leave out the files ignored by the `.gitignore` files of the repository, e.g. build artifacts in a `source_dir`. When `false`, the files of `add` and `source_dir` are committed even if they are ignored.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+			},
 			"remove": {
-				Description: "A file to remove. Contains the file path.",
+				Description: "A file to remove. Contains the file path, which can also be a directory, to remove all of its files, or a pattern with the syntax of `.gitignore`, e.g. `configs/**/old-*.yaml`. Paths matching no file are skipped.",
 				Type:        schema.TypeList,
 				Optional:    true,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"path": {
-							Type:     schema.TypeString,
-							Required: true,
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validateRepoPath,
+						},
+					},
+				},
+			},
+			"move": {
+				Description: "A file or directory to move, in the same commit as the other changes so git detects the rename. Moves are applied before `remove` and `add`, and are skipped when `from` no longer exists.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"from": {
+							Description:  "The path of the file or directory to move.",
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validateRepoPath,
+						},
+						"to": {
+							Description:  "The path to move it to.",
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validateRepoPath,
 						},
 					},
 				},
@@ -91,6 +325,67 @@ func resourceCommit() *schema.Resource {
 				Optional: true,
 				Default:  false,
 			},
+			"allow_protected_branch": {
+				Description: "Allow committing to a branch matching the provider's `protected_branches`.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"author":    identitySchema("The author of the commits. Defaults to the provider's `author`."),
+			"committer": identitySchema("The committer of the commits. Defaults to the provider's `committer`, or the author."),
+			"gpg_signing_key_file": {
+				Description: "The path of the armored OpenPGP private key the commits are signed with, instead of the provider's `gpg_signing_key`. The file is read when the commits are created, so the key is not stored in the Terraform state.",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"gpg_signing_key_passphrase_file": {
+				Description:  "The path of the passphrase of `gpg_signing_key_file`, if it is encrypted.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				RequiredWith: []string{"gpg_signing_key_file"},
+			},
+			"ssh_signing_key_file": {
+				Description:   "The path of the SSH private key the commits are signed with, like git's `gpg.format = ssh`, instead of the provider's signing key. The file is read when the commits are created, so the key is not stored in the Terraform state.",
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"gpg_signing_key_file"},
+			},
+			"ssh_signing_key_passphrase_file": {
+				Description:  "The path of the passphrase of `ssh_signing_key_file`, if it is encrypted.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				RequiredWith: []string{"ssh_signing_key_file"},
+			},
+			"reproducible": {
+				Description: "Create commits that only depend on the inputs, so the same inputs always yield the same commit sha, e.g. to compare runs in different environments. The author and committer timestamps are set to `timestamp` and, unless configured on the provider or the resource, their identity to a fixed one rather than the one of the environment variables or the git configuration.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"timestamp": {
+				Description:  "The RFC 3339 timestamp used for the author and committer of reproducible commits.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsRFC3339Time,
+			},
+			"author_date": {
+				Description:  "The RFC 3339 timestamp of the author of the commits, instead of the current time or `timestamp`.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsRFC3339Time,
+			},
+			"committer_date": {
+				Description:  "The RFC 3339 timestamp of the committer of the commits, instead of the current time or `timestamp`.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsRFC3339Time,
+			},
+			"deletion_protection": {
+				Description: "Prevent the resource from being destroyed. Must be set to false and applied before the resource can be destroyed or replaced.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
 			"sha": {
 				Description: "The git sha of the commit.",
 				Type:        schema.TypeString,
@@ -101,121 +396,457 @@ func resourceCommit() *schema.Resource {
 				Type:        schema.TypeBool,
 				Computed:    true,
 			},
+			"removed_count": {
+				Description: "The number of files removed by the `remove` blocks when the commit was created, e.g. to notice a pattern that matched no file.",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"change_id": {
+				Description: "The Gerrit `Change-Id` of the last commit, if its message has one.",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"push_messages": {
+				Description: "The messages sent by the remote when the commit was pushed.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"push_urls": {
+				Description: "The URLs in the messages sent by the remote when the commit was pushed, e.g. a link to open a merge request.",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
 		},
 	}
 }
 
-func resourceCommitCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	url := d.Get("url").(string)
-	branch := d.Get("branch").(string)
-	message := d.Get("message").(string)
-	addItems := d.Get("add").([]interface{})
-	removeItems := d.Get("remove").([]interface{})
+func resourceCommitAdd() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"path": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateRepoPath,
+			},
+			"content": {
+				Description: "The content of the file, which can be empty. Exactly one of `content`, `content_base64` and `source_file` must be set.",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"content_base64": {
+				Description:  "The base64 encoded content of the file, e.g. from `filebase64()`, for binary files that cannot be passed as a string. Exactly one of `content`, `content_base64` and `source_file` must be set.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringIsBase64,
+			},
+			"source_file": {
+				Description: "The path of a local file to copy, e.g. `\"${path.module}/files/app.yaml\"`, read when applying rather than stored in the plan and state. Changes to the file are detected when refreshing, which replaces the resource. Exactly one of `content`, `content_base64` and `source_file` must be set.",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"ignore_whitespace": {
+				Description: "Leave the file untouched if its content only differs in whitespace or blank lines.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"ensure_trailing_newline": {
+				Description: "End the content with a newline if it does not, so a missing trailing newline in the configuration neither produces a diff nor a commit when the file in the repository has one.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"format": {
+				Description:  "The format of the content, `yaml` or `json`. The file is left untouched, and changes to the content produce no diff, when they hold the same data, e.g. with keys in another order or different quoting, so reformatting the file does not produce a commit. The content is checked to be valid `yaml` or `json` when planning, if it is known, and before anything is committed.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{formatYAML, formatJSON}, false),
+			},
+			"executable": {
+				Description: "Set the execute bit of the file, e.g. for scripts. Otherwise new files are not executable and existing files keep their mode.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"append": {
+				Description: "Append the content to the file rather than replacing it, e.g. to add a line to a shared file, unless the file already contains it as whole lines. With `prune`, those lines are removed from the file rather than the file.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"eol": {
+				Description:  "The line endings the content is converted to before it is committed: `lf`, `crlf`, or `auto` to use the line endings of the file in the repository, and `lf` for new files. Binary content is left as is. Defaults to leaving the line endings as they are.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{eolLF, eolCRLF, eolAuto}, false),
+			},
+			"merge": {
+				Description: "Deep merge the content into the file rather than replacing it, so keys of the file that are not in the content, e.g. managed by people, are kept. The file is parsed with its `format` or, when it is not set, the one of its extension. Mappings are merged while other values are replaced, and null JSON values remove keys. With `prune`, the keys of the content are removed from the file rather than the file.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"create_only": {
+				Description: "Only write the file if it does not exist in the repository, e.g. to seed a default configuration file without overwriting later changes to it. Conflicts with `append` and `merge`.",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"expected_sha": {
+				Description:  "The blob sha the file in the repository must have for it to be changed, as printed by `git hash-object`, so changes made to the file since then fail the apply rather than being overwritten. It is checked when the block is added or changed.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringMatch(shaPattern, "must be a full sha"),
+			},
+			"conflict_strategy": {
+				Description:  "What to do when the file was changed in the repository since it was last applied: `overwrite` replaces the changes, `fail` refuses to apply, and `merge` merges them with the changes to `content` like a three-way merge, using the last applied `content` as base, and fails if they conflict. With `fail` and `merge`, changes to the file in the repository are not reported as drift. Only `overwrite` can be set with `append` or `merge` enabled. Defaults to `overwrite`.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{conflictStrategyFail, conflictStrategyOverwrite, conflictStrategyMerge}, false),
+			},
+		},
+	}
+}
 
-	auth := meta.(*http.BasicAuth)
+// hashAddItem hashes an add item. The content is hashed with its whitespace
+// collapsed when ignore_whitespace is set, so whitespace only changes in the
+// configuration do not produce a diff.
+func hashAddItem(v interface{}) int {
+	item := make(map[string]interface{})
+	for k, v := range v.(map[string]interface{}) {
+		item[k] = v
+	}
 
-	repo, err := gogit.CloneContext(ctx, memory.NewStorage(), memfs.New(), &gogit.CloneOptions{
-		URL:  url,
-		Auth: auth,
-	})
-	if err != nil {
-		return diag.Errorf("failed to clone repository: %s", err)
+	if ensureNewline, ok := item["ensure_trailing_newline"].(bool); ok && ensureNewline {
+		item["content"] = string(ensureTrailingNewline([]byte(item["content"].(string))))
+	}
+	if ignoreWhitespace, ok := item["ignore_whitespace"].(bool); ok && ignoreWhitespace {
+		item["content"] = collapseWhitespace(item["content"].(string))
+	}
+	if format, ok := item["format"].(string); ok && format != "" {
+		item["content"] = canonicalContent(item["content"].(string), format)
 	}
 
-	// Get the current worktree
-	worktree, err := repo.Worktree()
-	if err != nil {
-		return diag.Errorf("failed to get worktree: %s", err)
+	return schema.HashResource(resourceCommitAdd())(item)
+}
+
+func resourceCommitCustomizeDiff(_ context.Context, d *schema.ResourceDiff, meta interface{}) error {
+	cfg, hasConfig := meta.(*providerConfig)
+
+	// The guardrails are checked as soon as the values are known, at plan
+	// time rather than apply time
+	if hasConfig {
+		known := func(key string) string {
+			if !d.NewValueKnown(key) {
+				return ""
+			}
+			return d.Get(key).(string)
+		}
+		if err := cfg.checkBranch(known("branch"), known("target_ref"), d.Get("allow_protected_branch").(bool)); err != nil {
+			return err
+		}
+		if err := cfg.checkTarget(known("url"), known("push_url"), known("branch"), known("target_ref")); err != nil {
+			return err
+		}
 	}
 
-	// Resolve then checkout the specified branch
-	sha, err := repo.ResolveRevision(plumbing.Revision(plumbing.NewRemoteReferenceName("origin", branch)))
-	if err != nil && errors.Is(err, plumbing.ErrReferenceNotFound) {
-		sha, err = repo.ResolveRevision(plumbing.Revision(plumbing.NewBranchReferenceName(branch)))
+	// Only replace the resource if the URL points to a different repository
+	if d.Id() != "" && d.HasChange("url") {
+		oldURL, newURL := d.GetChange("url")
+		if normalizeURL(oldURL.(string)) != normalizeURL(newURL.(string)) {
+			if err := d.ForceNew("url"); err != nil {
+				return err
+			}
+		}
 	}
-	if err != nil {
-		return diag.Errorf("failed to resolve branch %s: %s", branch, err)
+
+	// Bundle files are read-only, so the commit has to be pushed elsewhere
+	if d.NewValueKnown("url") && d.NewValueKnown("push_url") && d.Get("push_url").(string) == "" {
+		if _, ok := bundlePath(d.Get("url").(string)); ok {
+			return fmt.Errorf("push_url must be set when url is a bundle file")
+		}
 	}
 
-	err = worktree.Checkout(&gogit.CheckoutOptions{
-		Hash:  *sha,
-		Force: true,
-	})
-	if err != nil {
-		return diag.Errorf("failed to checkout hash %s: %s", sha.String(), err)
+	if err := validateCommitMessages(d); err != nil {
+		return err
 	}
 
-	// Remove files
-	for _, item := range removeItems {
-		path := item.(map[string]interface{})["path"].(string)
+	if d.Get("reproducible").(bool) && d.NewValueKnown("timestamp") && d.Get("timestamp").(string) == "" {
+		return fmt.Errorf("timestamp must be set when reproducible is enabled")
+	}
 
-		path = worktree.Filesystem.Join(path)
+	if d.Get("split_commits").(bool) && d.Get("update_strategy").(string) == updateStrategyAmend {
+		return fmt.Errorf("split_commits cannot be set when update_strategy is amend")
+	}
 
-		// Remove the file
-		_, err := worktree.Remove(path)
-		if err != nil {
-			diag.Errorf("failed to remove file %s: %s", path, err)
-		}
+	if err := validateAddSources(d.GetRawConfig()); err != nil {
+		return err
 	}
 
-	// Write files
-	for _, item := range addItems {
+	// Reject duplicate add paths, which would otherwise silently overwrite each other
+	paths := make(map[string]bool)
+	for _, item := range d.Get("add").(*schema.Set).List() {
 		path := item.(map[string]interface{})["path"].(string)
-		content := item.(map[string]interface{})["content"].(string)
+		if path == "" {
+			continue
+		}
 
-		path = worktree.Filesystem.Join(path)
+		if format := item.(map[string]interface{})["format"].(string); format != "" && d.NewValueKnown("add") && item.(map[string]interface{})["source_file"].(string) == "" {
+			content, err := addContent(item.(map[string]interface{}))
+			if err != nil {
+				return err
+			}
+			if err := validateFormat(content, format); err != nil {
+				return fmt.Errorf("validation failed for %s: %w", path, err)
+			}
+		}
 
-		// Create, write then close file
-		file, err := worktree.Filesystem.Create(path)
-		if err != nil {
-			return diag.Errorf("failed to create file %s: %s", path, err)
+		modes := 0
+		for _, key := range []string{"create_only", "append", "merge"} {
+			if item.(map[string]interface{})[key].(bool) {
+				modes++
+			}
+		}
+		if modes > 1 {
+			return fmt.Errorf("only one of create_only, append and merge can be set for add path %s", path)
 		}
 
-		_, err = io.WriteString(file, content)
-		if err != nil {
-			return diag.Errorf("failed to write to file %s: %s", path, err)
+		// Appended and merged content is not the whole file, so there is no
+		// applied content to detect changes against
+		if strategy := item.(map[string]interface{})["conflict_strategy"].(string); strategy == conflictStrategyFail || strategy == conflictStrategyMerge {
+			if item.(map[string]interface{})["append"].(bool) || item.(map[string]interface{})["merge"].(bool) {
+				return fmt.Errorf("conflict_strategy %s cannot be set with append or merge for add path %s", strategy, path)
+			}
 		}
 
-		err = file.Close()
-		if err != nil {
-			return diag.Errorf("failed to close file %s: %s", path, err)
+		path = filepath.ToSlash(filepath.Clean(path))
+		if paths[path] {
+			return fmt.Errorf("duplicate add path %s: each path can only be added once", path)
 		}
+		paths[path] = true
 	}
 
-	// Check if worktree is clean
-	status, err := worktree.Status()
-	if err != nil {
-		return diag.Errorf("failed to compute worktree status: %s", err)
-	}
-	if status.IsClean() {
-		sha, err := repo.ResolveRevision(plumbing.Revision(plumbing.HEAD))
-		if err != nil {
-			return diag.Errorf("failed to get existing commit: %s", err)
-		}
+	return nil
+}
 
-		d.SetId(sha.String())
-		if err := d.Set("sha", sha.String()); err != nil {
-			return diag.Errorf("failed to set sha: %s", err)
-		}
-		if err := d.Set("new", false); err != nil {
-			return diag.Errorf("failed to set new: %s", err)
-		}
+// addSources are the attributes of an add item its content is read from.
+var addSources = []string{"content", "content_base64", "source_file"}
 
+// validateAddSources returns an error unless exactly one of the addSources is
+// set for every add item of the raw configuration. An empty content is set,
+// so it is told apart from a missing one by the raw configuration rather than
+// the state. Items with unknown sources are only checked once they are known.
+func validateAddSources(raw cty.Value) error {
+	if raw.IsNull() || !raw.IsKnown() {
 		return nil
 	}
-
-	// Stage worktree
-	err = worktree.AddWithOptions(&gogit.AddOptions{
-		All: true,
-	})
-	if err != nil {
-		return diag.Errorf("failed to stage worktree: %s", err)
+	add := raw.GetAttr("add")
+	if add.IsNull() || !add.IsKnown() {
+		return nil
+	}
+
+	for it := add.ElementIterator(); it.Next(); {
+		_, item := it.Element()
+		if item.IsNull() || !item.IsKnown() {
+			continue
+		}
+		path := "(unknown)"
+		if value := item.GetAttr("path"); value.IsKnown() && !value.IsNull() {
+			path = value.AsString()
+		}
+
+		sources, unknown := 0, false
+		for _, key := range addSources {
+			switch value := item.GetAttr(key); {
+			case !value.IsKnown():
+				unknown = true
+			case !value.IsNull():
+				sources++
+			}
+		}
+		if sources > 1 {
+			return fmt.Errorf("only one of content, content_base64 and source_file can be set for add path %s", path)
+		}
+		if sources == 0 && !unknown {
+			return fmt.Errorf("one of content, content_base64 and source_file must be set for add path %s", path)
+		}
+	}
+
+	return nil
+}
+
+func resourceCommitCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	url := d.Get("url").(string)
+	branch := d.Get("branch").(string)
+	targetRef := d.Get("target_ref").(string)
+
+	cfg := meta.(*providerConfig)
+	if err := cfg.checkWritable(); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := cfg.checkBranch(branch, targetRef, d.Get("allow_protected_branch").(bool)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := cfg.checkTarget(url, d.Get("push_url").(string), branch, targetRef); err != nil {
+		return diag.FromErr(err)
+	}
+
+	_, auth, err := cfg.resolveRemote(ctx, cfg.rewriteURL(url))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	addItems, err := commitItems(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if diags := validateFiles(ctx, addItems, d.Get("validation").([]interface{})); diags.HasError() {
+		return diags
+	}
+
+	return pushWithRetries(ctx, d, cfg, auth, commitCreate)
+}
+
+// commitCreate clones the repository, commits the files of the resource and
+// pushes them, reporting whether the push was rejected because the branch
+// was updated meanwhile.
+func commitCreate(ctx context.Context, d *schema.ResourceData, cfg *providerConfig, auth transport.AuthMethod) (bool, diag.Diagnostics) {
+	branch := d.Get("branch").(string)
+	targetRef := d.Get("target_ref").(string)
+	message := d.Get("message").(string)
+	removeItems := d.Get("remove").([]interface{})
+
+	addItems, err := commitItems(d)
+	if err != nil {
+		return false, diag.FromErr(err)
+	}
+
+	repo, diags := cloneCommitRepository(ctx, d, cfg, true)
+	if diags.HasError() {
+		return false, diags
+	}
+
+	// Get the current worktree
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return false, diag.Errorf("failed to get worktree: %s", err)
+	}
+
+	// Resolve then checkout the commit to build on
+	sha, err := resolveCommitBase(ctx, d, repo, auth)
+	if err != nil && !errors.Is(err, errRefNotFound) {
+		return false, diag.FromErr(err)
+	}
+
+	// Refuse to extend history that is not signed by an allowed signer
+	if err := verifyBaseSigned(repo, sha, d.Get("allowed_signers").([]interface{})); err != nil {
+		return false, diag.FromErr(err)
+	}
+
+	if sha == nil {
+		if err := checkoutOrphan(repo, worktree, branch); err != nil {
+			return false, diag.Errorf("failed to create orphan branch %s: %s", branch, err)
+		}
+	} else if err := checkoutCommit(worktree, *sha); err != nil {
+		return false, diag.Errorf("failed to checkout hash %s: %s", sha.String(), err)
+	}
+
+	// Check the files were not changed since they were read
+	if diags := checkExpectedSHAs(worktree, addItems, nil); diags.HasError() {
+		return false, diags
+	}
+
+	// Move files
+	if diags := moveFiles(worktree, d.Get("move").([]interface{})); diags.HasError() {
+		return false, diags
+	}
+
+	// Remove files
+	removed, diags := removeFiles(ctx, worktree, removeItems)
+	if diags.HasError() {
+		return false, diags
+	}
+	if err := d.Set("removed_count", removed); err != nil {
+		return false, diag.Errorf("failed to set removed_count: %s", err)
+	}
+
+	// Prune the files not in the source directories
+	if d.Get("prune").(bool) {
+		if err := pruneSourceDirs(worktree, d.Get("source_dir").([]interface{}), itemPaths(addItems)); err != nil {
+			return false, diag.FromErr(err)
+		}
+	}
+
+	// Write files
+	if diags := writeFiles(worktree, addItems); diags.HasError() {
+		return false, diags
+	}
+
+	// Set values
+	if diags := setYAMLValues(worktree, d.Get("yaml_set").([]interface{})); diags.HasError() {
+		return false, diags
+	}
+
+	// Patch files
+	if diags := patchJSONFiles(worktree, d.Get("json_patch").([]interface{})); diags.HasError() {
+		return false, diags
+	}
+
+	// Override images
+	if diags := setKustomizeImages(worktree, d.Get("kustomize_image").([]interface{})); diags.HasError() {
+		return false, diags
+	}
+
+	// Stage worktree
+	if err := stageWorktree(worktree, managedPaths(d, addItems), d.Get("stage_managed_only").(bool), d.Get("respect_gitignore").(bool)); err != nil {
+		return false, diag.FromErr(err)
+	}
+
+	// Check if worktree is clean
+	status, err := worktree.Status()
+	if err != nil {
+		return false, diag.Errorf("failed to compute worktree status: %s", err)
+	}
+	if status.IsClean() && !d.Get("allow_empty").(bool) {
+		sha, err := repo.ResolveRevision(plumbing.Revision(plumbing.HEAD))
+		if err != nil {
+			return false, diag.Errorf("failed to get existing commit: %s", err)
+		}
+
+		// The tags still point to the existing commit
+		if diags := pushExistingCommitTags(ctx, d, cfg, repo, *sha, auth, nil); diags.HasError() {
+			return false, diags
+		}
+
+		d.SetId(sha.String())
+		if err := d.Set("sha", sha.String()); err != nil {
+			return false, diag.Errorf("failed to set sha: %s", err)
+		}
+		if err := d.Set("new", false); err != nil {
+			return false, diag.Errorf("failed to set new: %s", err)
+		}
+
+		return false, nil
 	}
 
 	// Commit
-	commitSha, err := worktree.Commit(message, &gogit.CommitOptions{})
+	var commitSha plumbing.Hash
+	if d.Get("split_commits").(bool) {
+		commitSha, err = createSplitCommits(ctx, d, cfg, repo, worktree, message, addItems, removeItems)
+	} else {
+		commitSha, err = createCommit(ctx, d, cfg, repo, worktree, message, nil)
+	}
 	if err != nil {
-		return diag.Errorf("failed to commit: %s", err)
+		return false, diag.FromErr(err)
 	}
 
 	// Update branch
@@ -223,43 +854,74 @@ func resourceCommitCreate(ctx context.Context, d *schema.ResourceData, meta inte
 	hashRef := plumbing.NewHashReference(branchRef, commitSha)
 	err = repo.Storer.SetReference(hashRef)
 	if err != nil {
-		return diag.Errorf("failed to set branch ref: %s", err)
+		return false, diag.Errorf("failed to set branch ref: %s", err)
+	}
+
+	// Tag
+	tags, err := createTags(repo, d.Get("tags").([]interface{}), commitSha, nil)
+	if err != nil {
+		return false, diag.FromErr(err)
+	}
+
+	// Refuse to push on top of another commit than the expected parent
+	if expected := d.Get("expected_parent_sha").(string); expected != "" {
+		parent := plumbing.NewHash(expected)
+		if diags := checkExpectedParent(ctx, cfg, repo, remoteName(d), d.Get("push_url").(string), pushRef(branch, targetRef), auth, parent); diags.HasError() {
+			return false, diags
+		}
 	}
 
 	// Push
-	err = repo.PushContext(ctx, &gogit.PushOptions{
-		RefSpecs: []config.RefSpec{
-			config.RefSpec(fmt.Sprintf("%s:%s", branchRef, branchRef)),
-		},
-		Auth: auth,
+	messages, rejected, diags := pushBranch(ctx, cfg, repo, pushBranchOptions{
+		remoteName: remoteName(d),
+		pushURL:    d.Get("push_url").(string),
+		branchRef:  branchRef,
+		remoteRef:  pushRef(branch, targetRef),
+		tags:       tags,
+		auth:       auth,
+		options:    pushOptions(d),
 	})
-	if err != nil {
-		return diag.Errorf("failed to push: %s", err)
+	if diags.HasError() {
+		return rejected, diags
+	}
+	if diags := setPushOutput(d, messages); diags.HasError() {
+		return false, diags
 	}
 
 	d.SetId(commitSha.String())
 	if err := d.Set("sha", commitSha.String()); err != nil {
-		return diag.Errorf("error setting sha: %s", err)
+		return false, diag.Errorf("error setting sha: %s", err)
 	}
 	if err := d.Set("new", true); err != nil {
-		return diag.Errorf("error setting new: %s", err)
+		return false, diag.Errorf("error setting new: %s", err)
 	}
 
-	return nil
+	return false, nil
 }
 
 func resourceCommitRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	url := d.Get("url").(string)
 	branch := d.Get("branch").(string)
-	items := d.Get("add").([]interface{})
+	targetRef := d.Get("target_ref").(string)
 	removeItems := d.Get("remove").([]interface{})
 
-	auth := meta.(*http.BasicAuth)
+	items, err := commitItems(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
 
-	repo, err := gogit.CloneContext(ctx, memory.NewStorage(), memfs.New(), &gogit.CloneOptions{
-		URL:  url,
-		Auth: auth,
-	})
+	// Read back the commit from where it was pushed
+	if pushURL, ok := d.GetOk("push_url"); ok {
+		url = pushURL.(string)
+	}
+
+	cfg := meta.(*providerConfig)
+	_, auth, err := cfg.resolveRemote(ctx, cfg.rewriteURL(url))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	repo, err := cfg.clone(ctx, url, remoteName(d), memfs.New())
 	if err != nil {
 		return diag.Errorf("failed to clone repository: %s", err)
 	}
@@ -270,58 +932,61 @@ func resourceCommitRead(ctx context.Context, d *schema.ResourceData, meta interf
 		return diag.Errorf("failed to get worktree: %s", err)
 	}
 
-	// Resolve then checkout the specified branch
-	sha, err := repo.ResolveRevision(plumbing.Revision(plumbing.NewRemoteReferenceName("origin", branch)))
-	if err != nil && errors.Is(err, plumbing.ErrReferenceNotFound) {
-		sha, err = repo.ResolveRevision(plumbing.Revision(plumbing.NewBranchReferenceName(branch)))
-	}
-	if err != nil {
-		return diag.Errorf("failed to resolve branch %s: %s", branch, err)
+	// Resolve then checkout the commit to compare against
+	sha, err := resolveBase(ctx, repo, remoteName(d), branch, targetRef, auth)
+	if errors.Is(err, errRefNotFound) {
+		// Refs that are not advertised by the remote, such as Gerrit's
+		// refs/for/*, cannot be read back so drift is not detected
+		return nil
+	} else if err != nil {
+		return diag.FromErr(err)
 	}
 
-	err = worktree.Checkout(&gogit.CheckoutOptions{
-		Hash:  *sha,
-		Force: true,
-	})
+	err = checkoutCommit(worktree, *sha)
 	if err != nil {
 		return diag.Errorf("failed to checkout hash %s: %s", sha.String(), err)
 	}
 
-	// Remove files
-	for _, item := range removeItems {
-		path := item.(map[string]interface{})["path"].(string)
-
-		path = worktree.Filesystem.Join(path)
+	// Move files
+	if diags := moveFiles(worktree, d.Get("move").([]interface{})); diags.HasError() {
+		return diags
+	}
 
-		// Remove the file
-		_, err := worktree.Remove(path)
-		if err != nil {
-			diag.Errorf("failed to remove file %s: %s", path, err)
-		}
+	// Remove files
+	if _, diags := removeFiles(ctx, worktree, removeItems); diags.HasError() {
+		return diags
 	}
 
-	// Write files
+	// Write files, except the ones whose changes in the repository are kept
+	var checkedItems []interface{}
 	for _, item := range items {
-		path := item.(map[string]interface{})["path"].(string)
-		content := item.(map[string]interface{})["content"].(string)
+		if strategy := item.(map[string]interface{})["conflict_strategy"].(string); strategy == conflictStrategyFail || strategy == conflictStrategyMerge {
+			continue
+		}
+		checkedItems = append(checkedItems, item)
+	}
+	if diags := writeFiles(worktree, checkedItems); diags.HasError() {
+		return diags
+	}
 
-		path = worktree.Filesystem.Join(path)
+	// Set values
+	if diags := setYAMLValues(worktree, d.Get("yaml_set").([]interface{})); diags.HasError() {
+		return diags
+	}
 
-		// Create, write then close file
-		file, err := worktree.Filesystem.Create(path)
-		if err != nil {
-			return diag.Errorf("failed to create file %s: %s", path, err)
-		}
+	// Patch files
+	if diags := patchJSONFiles(worktree, d.Get("json_patch").([]interface{})); diags.HasError() {
+		return diags
+	}
 
-		_, err = io.WriteString(file, content)
-		if err != nil {
-			return diag.Errorf("failed to write to file %s: %s", path, err)
-		}
+	// Override images
+	if diags := setKustomizeImages(worktree, d.Get("kustomize_image").([]interface{})); diags.HasError() {
+		return diags
+	}
 
-		err = file.Close()
-		if err != nil {
-			return diag.Errorf("failed to close file %s: %s", path, err)
-		}
+	// Stage worktree
+	if err := stageWorktree(worktree, managedPaths(d, checkedItems), d.Get("stage_managed_only").(bool), d.Get("respect_gitignore").(bool)); err != nil {
+		return diag.FromErr(err)
 	}
 
 	// Check if worktree is clean
@@ -348,8 +1013,45 @@ func resourceCommitRead(ctx context.Context, d *schema.ResourceData, meta interf
 func resourceCommitUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	url := d.Get("url").(string)
 	branch := d.Get("branch").(string)
+	targetRef := d.Get("target_ref").(string)
+
+	cfg := meta.(*providerConfig)
+	if err := cfg.checkWritable(); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := cfg.checkBranch(branch, targetRef, d.Get("allow_protected_branch").(bool)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := cfg.checkTarget(url, d.Get("push_url").(string), branch, targetRef); err != nil {
+		return diag.FromErr(err)
+	}
+
+	_, auth, err := cfg.resolveRemote(ctx, cfg.rewriteURL(url))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	items, err := commitItems(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if diags := validateFiles(ctx, items, d.Get("validation").([]interface{})); diags.HasError() {
+		return diags
+	}
+
+	return pushWithRetries(ctx, d, cfg, auth, commitUpdate)
+}
+
+// commitUpdate clones the repository, commits the changes of the resource and
+// pushes them, reporting whether the push was rejected because the branch
+// was updated meanwhile.
+func commitUpdate(ctx context.Context, d *schema.ResourceData, cfg *providerConfig, auth transport.AuthMethod) (bool, diag.Diagnostics) {
+	branch := d.Get("branch").(string)
+	targetRef := d.Get("target_ref").(string)
 	message := d.Get("message").(string)
-	items := d.Get("add").([]interface{})
 	prune := d.Get("prune").(bool)
 	removeItems := d.Get("remove").([]interface{})
 
@@ -357,126 +1059,169 @@ func resourceCommitUpdate(ctx context.Context, d *schema.ResourceData, meta inte
 		message = updateMessage.(string)
 	}
 
-	auth := meta.(*http.BasicAuth)
-
-	repo, err := gogit.CloneContext(ctx, memory.NewStorage(), memfs.New(), &gogit.CloneOptions{
-		URL:  url,
-		Auth: auth,
-	})
+	items, err := commitItems(d)
 	if err != nil {
-		return diag.Errorf("failed to clone repository: %s", err)
+		return false, diag.FromErr(err)
+	}
+
+	repo, diags := cloneCommitRepository(ctx, d, cfg, true)
+	if diags.HasError() {
+		return false, diags
 	}
 
 	// Get the current worktree
 	worktree, err := repo.Worktree()
 	if err != nil {
-		return diag.Errorf("failed to get worktree: %s", err)
+		return false, diag.Errorf("failed to get worktree: %s", err)
 	}
 
-	// Resolve then checkout the specified branch
-	sha, err := repo.ResolveRevision(plumbing.Revision(plumbing.NewRemoteReferenceName("origin", branch)))
-	if err != nil && errors.Is(err, plumbing.ErrReferenceNotFound) {
-		sha, err = repo.ResolveRevision(plumbing.Revision(plumbing.NewBranchReferenceName(branch)))
-	}
-	if err != nil {
-		return diag.Errorf("failed to resolve branch %s: %s", branch, err)
+	// Resolve then checkout the commit to build on
+	sha, err := resolveCommitBase(ctx, d, repo, auth)
+	if err != nil && !errors.Is(err, errRefNotFound) {
+		return false, diag.FromErr(err)
 	}
 
-	err = worktree.Checkout(&gogit.CheckoutOptions{
-		Hash:  *sha,
-		Force: true,
-	})
-	if err != nil {
-		return diag.Errorf("failed to checkout hash %s: %s", sha.String(), err)
+	// Refuse to extend history that is not signed by an allowed signer
+	if err := verifyBaseSigned(repo, sha, d.Get("allowed_signers").([]interface{})); err != nil {
+		return false, diag.FromErr(err)
 	}
 
-	// Remove files
-	for _, item := range removeItems {
-		path := item.(map[string]interface{})["path"].(string)
-
-		path = worktree.Filesystem.Join(path)
+	if sha == nil {
+		if err := checkoutOrphan(repo, worktree, branch); err != nil {
+			return false, diag.Errorf("failed to create orphan branch %s: %s", branch, err)
+		}
+	} else if err := checkoutCommit(worktree, *sha); err != nil {
+		return false, diag.Errorf("failed to checkout hash %s: %s", sha.String(), err)
+	}
 
-		// Remove the file
-		_, err := worktree.Remove(path)
+	// Amend the commit of the resource, unless commits were pushed on top of it
+	var parents []plumbing.Hash
+	var lease *plumbing.Hash
+	if d.Get("update_strategy").(string) == updateStrategyAmend && sha != nil && sha.String() == d.Get("sha").(string) {
+		previous, err := repo.CommitObject(*sha)
 		if err != nil {
-			diag.Errorf("failed to remove file %s: %s", path, err)
+			return false, diag.Errorf("failed to get commit %s: %s", sha, err)
+		}
+		if len(previous.ParentHashes) > 0 {
+			parents = previous.ParentHashes
+			lease = sha
+			message = d.Get("message").(string)
+		} else {
+			// go-git always commits on top of HEAD when no parent is given
+			tflog.Info(ctx, "the commit to amend is a root commit, committing on top of it", map[string]interface{}{
+				"sha": sha.String(),
+			})
 		}
 	}
 
+	// Check the files were not changed since they were read
+	applied, _ := d.GetChange("add")
+	if diags := checkExpectedSHAs(worktree, items, applied.(*schema.Set).List()); diags.HasError() {
+		return false, diags
+	}
+
+	// Move files
+	if diags := moveFiles(worktree, d.Get("move").([]interface{})); diags.HasError() {
+		return false, diags
+	}
+
+	// Remove files
+	removed, diags := removeFiles(ctx, worktree, removeItems)
+	if diags.HasError() {
+		return false, diags
+	}
+	if err := d.Set("removed_count", removed); err != nil {
+		return false, diag.Errorf("failed to set removed_count: %s", err)
+	}
+
 	// Prune files
 	if prune && d.HasChange("add") {
 		oldItems, _ := d.GetChange("add")
 
-		for _, item := range oldItems.([]interface{}) {
-			path := item.(map[string]interface{})["path"].(string)
-			path = worktree.Filesystem.Join(path)
-
+		for _, item := range oldItems.(*schema.Set).List() {
 			// Delete old files
-			_, err = worktree.Remove(path)
-			if err != nil && !errors.Is(err, index.ErrEntryNotFound) {
-				return diag.Errorf("failed to delete file %s: %s", path, err)
+			if err := pruneFile(worktree, item.(map[string]interface{})); err != nil {
+				return false, diag.FromErr(err)
 			}
 		}
 	}
 
+	// Prune the files no longer in the source directories
+	if prune {
+		oldDirs, newDirs := d.GetChange("source_dir")
+		if err := pruneSourceDirs(worktree, append(oldDirs.([]interface{}), newDirs.([]interface{})...), itemPaths(items)); err != nil {
+			return false, diag.FromErr(err)
+		}
+	}
+
+	// Merge or refuse the changes made to the files since they were applied
+	items, diags = resolveConflicts(worktree, items, applied.(*schema.Set).List())
+	if diags.HasError() {
+		return false, diags
+	}
+
 	// Write files
-	for _, item := range items {
-		path := item.(map[string]interface{})["path"].(string)
-		content := item.(map[string]interface{})["content"].(string)
+	if diags := writeFiles(worktree, items); diags.HasError() {
+		return false, diags
+	}
 
-		path = worktree.Filesystem.Join(path)
+	// Set values
+	if diags := setYAMLValues(worktree, d.Get("yaml_set").([]interface{})); diags.HasError() {
+		return false, diags
+	}
 
-		// Create, write then close file
-		file, err := worktree.Filesystem.Create(path)
-		if err != nil {
-			return diag.Errorf("failed to create file %s: %s", path, err)
-		}
+	// Patch files
+	if diags := patchJSONFiles(worktree, d.Get("json_patch").([]interface{})); diags.HasError() {
+		return false, diags
+	}
 
-		_, err = io.WriteString(file, content)
-		if err != nil {
-			return diag.Errorf("failed to write to file %s: %s", path, err)
-		}
+	// Override images
+	if diags := setKustomizeImages(worktree, d.Get("kustomize_image").([]interface{})); diags.HasError() {
+		return false, diags
+	}
 
-		err = file.Close()
-		if err != nil {
-			return diag.Errorf("failed to close file %s: %s", path, err)
-		}
+	// Stage worktree
+	oldItems, _ := d.GetChange("add")
+	if err := stageWorktree(worktree, managedPaths(d, append(items, oldItems.(*schema.Set).List()...)), d.Get("stage_managed_only").(bool), d.Get("respect_gitignore").(bool)); err != nil {
+		return false, diag.FromErr(err)
 	}
 
 	// Check if worktree is clean
 	status, err := worktree.Status()
 	if err != nil {
-		return diag.Errorf("failed to compute worktree status: %s", err)
+		return false, diag.Errorf("failed to compute worktree status: %s", err)
 	}
-	if status.IsClean() {
+	if status.IsClean() && !d.Get("allow_empty").(bool) {
 		sha, err := repo.ResolveRevision(plumbing.Revision(plumbing.HEAD))
 		if err != nil {
-			return diag.Errorf("failed to get existing commit: %s", err)
+			return false, diag.Errorf("failed to get existing commit: %s", err)
+		}
+
+		// The new tags still point to the existing commit
+		if diags := pushExistingCommitTags(ctx, d, cfg, repo, *sha, auth, previousTagNames(d)); diags.HasError() {
+			return false, diags
 		}
 
 		d.SetId(sha.String())
 		if err := d.Set("sha", sha.String()); err != nil {
-			return diag.Errorf("failed to set sha: %s", err)
+			return false, diag.Errorf("failed to set sha: %s", err)
 		}
 		if err := d.Set("new", false); err != nil {
-			return diag.Errorf("failed to set new: %s", err)
+			return false, diag.Errorf("failed to set new: %s", err)
 		}
 
-		return nil
-	}
-
-	// Stage worktree
-	err = worktree.AddWithOptions(&gogit.AddOptions{
-		All: true,
-	})
-	if err != nil {
-		return diag.Errorf("failed to stage worktree: %s", err)
+		return false, nil
 	}
 
 	// Commit
-	commitSha, err := worktree.Commit(message, &gogit.CommitOptions{})
+	var commitSha plumbing.Hash
+	if d.Get("split_commits").(bool) && parents == nil {
+		commitSha, err = createSplitCommits(ctx, d, cfg, repo, worktree, message, items, d.Get("remove").([]interface{}))
+	} else {
+		commitSha, err = createCommit(ctx, d, cfg, repo, worktree, message, parents)
+	}
 	if err != nil {
-		return diag.Errorf("failed to commit: %s", err)
+		return false, diag.FromErr(err)
 	}
 
 	// Update branch
@@ -484,36 +1229,89 @@ func resourceCommitUpdate(ctx context.Context, d *schema.ResourceData, meta inte
 	hashRef := plumbing.NewHashReference(branchRef, commitSha)
 	err = repo.Storer.SetReference(hashRef)
 	if err != nil {
-		return diag.Errorf("failed to set branch ref: %s", err)
+		return false, diag.Errorf("failed to set branch ref: %s", err)
+	}
+
+	// Tag, except with the tags created with previous commits
+	tags, err := createTags(repo, d.Get("tags").([]interface{}), commitSha, previousTagNames(d))
+	if err != nil {
+		return false, diag.FromErr(err)
+	}
+
+	// Refuse to push on top of another commit than the expected parent
+	if expected := d.Get("expected_parent_sha").(string); expected != "" {
+		parent := plumbing.NewHash(expected)
+		if diags := checkExpectedParent(ctx, cfg, repo, remoteName(d), d.Get("push_url").(string), pushRef(branch, targetRef), auth, parent); diags.HasError() {
+			return false, diags
+		}
 	}
 
 	// Push
-	err = repo.PushContext(ctx, &gogit.PushOptions{
-		RefSpecs: []config.RefSpec{
-			config.RefSpec(fmt.Sprintf("%s:%s", branchRef, branchRef)),
-		},
-		Auth: auth,
+	messages, rejected, diags := pushBranch(ctx, cfg, repo, pushBranchOptions{
+		remoteName: remoteName(d),
+		pushURL:    d.Get("push_url").(string),
+		branchRef:  branchRef,
+		remoteRef:  pushRef(branch, targetRef),
+		tags:       tags,
+		auth:       auth,
+		options:    pushOptions(d),
+		lease:      lease,
 	})
-	if err != nil {
-		return diag.Errorf("failed to push: %s", err)
+	if diags.HasError() {
+		return rejected, diags
+	}
+	if diags := setPushOutput(d, messages); diags.HasError() {
+		return false, diags
 	}
 
-	d.SetId(sha.String())
+	d.SetId(commitSha.String())
 	if err := d.Set("sha", commitSha.String()); err != nil {
-		return diag.Errorf("failed to set sha: %s", err)
+		return false, diag.Errorf("failed to set sha: %s", err)
 	}
 	if err := d.Set("new", true); err != nil {
-		return diag.Errorf("failed to set new: %s", err)
+		return false, diag.Errorf("failed to set new: %s", err)
 	}
 
-	return nil
+	return false, nil
 }
 
 func resourceCommitDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	url := d.Get("url").(string)
+	if d.Get("deletion_protection").(bool) {
+		return diag.Errorf("cannot destroy commit %s: deletion_protection is enabled, set it to false and apply before destroying", d.Id())
+	}
+
+	url := d.Get("url").(string)
+	branch := d.Get("branch").(string)
+	targetRef := d.Get("target_ref").(string)
+	cfg := meta.(*providerConfig)
+	if err := cfg.checkWritable(); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := cfg.checkBranch(branch, targetRef, d.Get("allow_protected_branch").(bool)); err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := cfg.checkTarget(url, d.Get("push_url").(string), branch, targetRef); err != nil {
+		return diag.FromErr(err)
+	}
+
+	_, auth, err := cfg.resolveRemote(ctx, cfg.rewriteURL(url))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	return pushWithRetries(ctx, d, cfg, auth, commitDelete)
+}
+
+// commitDelete clones the repository, commits the removal of the files of the
+// resource and pushes it, reporting whether the push was rejected because the
+// branch was updated meanwhile.
+func commitDelete(ctx context.Context, d *schema.ResourceData, cfg *providerConfig, auth transport.AuthMethod) (bool, diag.Diagnostics) {
 	branch := d.Get("branch").(string)
+	targetRef := d.Get("target_ref").(string)
 	message := d.Get("message").(string)
-	items := d.Get("add").([]interface{})
+	items := d.Get("add").(*schema.Set).List()
 	prune := d.Get("prune").(bool)
 	removeItems := d.Get("remove").([]interface{})
 
@@ -522,87 +1320,71 @@ func resourceCommitDelete(ctx context.Context, d *schema.ResourceData, meta inte
 	} else if updateMessage, ok := d.GetOk("update_message"); ok {
 		message = updateMessage.(string)
 	}
-	auth := meta.(*http.BasicAuth)
 
-	repo, err := gogit.CloneContext(ctx, memory.NewStorage(), memfs.New(), &gogit.CloneOptions{
-		URL:  url,
-		Auth: auth,
-	})
-	if err != nil {
-		return diag.Errorf("failed to clone repository: %s", err)
+	repo, diags := cloneCommitRepository(ctx, d, cfg, false)
+	if diags.HasError() {
+		return false, diags
 	}
 
 	// Get the current worktree
 	worktree, err := repo.Worktree()
 	if err != nil {
-		return diag.Errorf("failed to get worktree: %s", err)
+		return false, diag.Errorf("failed to get worktree: %s", err)
 	}
 
-	// Resolve then checkout the specified branch
-	sha, err := repo.ResolveRevision(plumbing.Revision(plumbing.NewRemoteReferenceName("origin", branch)))
-	if err != nil && errors.Is(err, plumbing.ErrReferenceNotFound) {
-		sha, err = repo.ResolveRevision(plumbing.Revision(plumbing.NewBranchReferenceName(branch)))
+	// Resolve then checkout the commit to build on
+	sha, err := resolveBase(ctx, repo, remoteName(d), branch, targetRef, auth)
+	if err != nil && !errors.Is(err, errRefNotFound) {
+		return false, diag.FromErr(err)
 	}
-	if err != nil {
-		return diag.Errorf("failed to resolve branch %s: %s", branch, err)
+
+	// Refuse to extend history that is not signed by an allowed signer
+	if err := verifyBaseSigned(repo, sha, d.Get("allowed_signers").([]interface{})); err != nil {
+		return false, diag.FromErr(err)
 	}
 
-	err = worktree.Checkout(&gogit.CheckoutOptions{
-		Hash:  *sha,
-		Force: true,
-	})
+	err = checkoutCommit(worktree, *sha)
 	if err != nil {
-		return diag.Errorf("failed to checkout hash %s: %s", sha.String(), err)
+		return false, diag.Errorf("failed to checkout hash %s: %s", sha.String(), err)
 	}
 
 	// Remove files
-	for _, item := range removeItems {
-		path := item.(map[string]interface{})["path"].(string)
-
-		path = worktree.Filesystem.Join(path)
-
-		// Remove the file
-		_, err := worktree.Remove(path)
-		if err != nil {
-			diag.Errorf("failed to remove file %s: %s", path, err)
-		}
+	if _, diags := removeFiles(ctx, worktree, removeItems); diags.HasError() {
+		return false, diags
 	}
 
 	// Prune files
 	if prune {
 		for _, item := range items {
-			path := item.(map[string]interface{})["path"].(string)
-			path = worktree.Filesystem.Join(path)
-
 			// Delete all files
-			_, err = worktree.Remove(path)
-			if err != nil && !errors.Is(err, index.ErrEntryNotFound) {
-				return diag.Errorf("failed to delete file %s: %s", path, err)
+			if err := pruneFile(worktree, item.(map[string]interface{})); err != nil {
+				return false, diag.FromErr(err)
 			}
 		}
+
+		if err := pruneSourceDirs(worktree, d.Get("source_dir").([]interface{}), nil); err != nil {
+			return false, diag.FromErr(err)
+		}
 	}
 
 	// Check if worktree is clean
 	status, err := worktree.Status()
 	if err != nil {
-		return diag.Errorf("failed to compute worktree status: %s", err)
+		return false, diag.Errorf("failed to compute worktree status: %s", err)
 	}
 	if status.IsClean() {
-		return nil
+		return false, nil
 	}
 
 	// Stage worktree
-	err = worktree.AddWithOptions(&gogit.AddOptions{
-		All: true,
-	})
-	if err != nil {
-		return diag.Errorf("failed to stage worktree: %s", err)
+	if err := stageWorktree(worktree, managedPaths(d, items), d.Get("stage_managed_only").(bool), d.Get("respect_gitignore").(bool)); err != nil {
+		return false, diag.FromErr(err)
 	}
 
 	// Commit
-	commitSha, err := worktree.Commit(message, &gogit.CommitOptions{})
+	commitSha, err := createCommit(ctx, d, cfg, repo, worktree, message, nil)
 	if err != nil {
-		return diag.Errorf("failed to commit: %s", err)
+		return false, diag.FromErr(err)
 	}
 
 	// Update branch
@@ -610,19 +1392,1679 @@ func resourceCommitDelete(ctx context.Context, d *schema.ResourceData, meta inte
 	hashRef := plumbing.NewHashReference(branchRef, commitSha)
 	err = repo.Storer.SetReference(hashRef)
 	if err != nil {
-		return diag.Errorf("failed to set branch ref: %s", err)
+		return false, diag.Errorf("failed to set branch ref: %s", err)
 	}
 
 	// Push
-	err = repo.PushContext(ctx, &gogit.PushOptions{
+	if _, rejected, diags := pushBranch(ctx, cfg, repo, pushBranchOptions{
+		remoteName: remoteName(d),
+		pushURL:    d.Get("push_url").(string),
+		branchRef:  branchRef,
+		remoteRef:  pushRef(branch, targetRef),
+		auth:       auth,
+		options:    pushOptions(d),
+	}); diags.HasError() {
+		return rejected, diags
+	}
+
+	return false, nil
+}
+
+// reproducibleSignature is the identity used for reproducible commits when
+// no identity is configured, so the commit does not depend on the environment
+// the provider runs in.
+var reproducibleSignature = object.Signature{
+	Name:  "Terraform",
+	Email: "terraform@localhost",
+}
+
+// createCommit commits the staged changes of worktree with the message and
+// the options of the resource, signing the commit with an SSH key or Sigstore
+// if configured. The parents of the commit default to HEAD.
+func createCommit(ctx context.Context, d *schema.ResourceData, cfg *providerConfig, repo *gogit.Repository, worktree *gogit.Worktree, message string, parents []plumbing.Hash) (plumbing.Hash, error) {
+	commitOpts, err := commitOptions(d, cfg, repo)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if parents != nil {
+		commitOpts.Parents = parents
+	}
+
+	message = commitMessage(d, cfg, message, commitOpts)
+	commitSha, err := worktree.Commit(message, commitOpts)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to commit: %w", err)
+	}
+
+	changeID := ""
+	if match := changeIDPattern.FindStringSubmatch(message); match != nil {
+		changeID = match[1]
+	}
+	if err := d.Set("change_id", changeID); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to set change_id: %w", err)
+	}
+
+	var sign commitSigner
+	switch {
+	case d.Get("ssh_signing_key_file").(string) != "":
+		key, err := readSecret("ssh_signing_key", "", d.Get("ssh_signing_key_file").(string))
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		passphrase, err := readSecret("ssh_signing_key_passphrase", "", d.Get("ssh_signing_key_passphrase_file").(string))
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		signer, err := readSSHSigningKey(key, passphrase)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		sign = sshCommitSigner(signer)
+	case commitOpts.SignKey != nil:
+		// Signed with OpenPGP by go-git
+	case cfg.sshSigner != nil:
+		sign = sshCommitSigner(cfg.sshSigner)
+	case cfg.sigstore != nil:
+		sign = cfg.sigstore.sign
+	}
+	if sign == nil {
+		return commitSha, nil
+	}
+
+	return signCommit(ctx, repo, commitSha, sign)
+}
+
+// createSplitCommits commits the changes staged in worktree with a commit for
+// the files of each add item, in the order of their paths, and for the files
+// of each remove item, with the split_message of the resource, then a last
+// commit with message for the other changes. It returns the last commit.
+func createSplitCommits(ctx context.Context, d *schema.ResourceData, cfg *providerConfig, repo *gogit.Repository, worktree *gogit.Worktree, message string, items, removeItems []interface{}) (plumbing.Hash, error) {
+	status, err := worktree.Status()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to compute worktree status: %w", err)
+	}
+	var changed []string
+	for name, file := range status {
+		if file.Staging != gogit.Unmodified && file.Staging != gogit.Untracked {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+
+	// The files are staged again commit by commit
+	var parents []plumbing.Hash
+	head, err := repo.Head()
+	switch {
+	case errors.Is(err, plumbing.ErrReferenceNotFound):
+		err = repo.Storer.SetIndex(&index.Index{Version: 2})
+	case err == nil:
+		parents = []plumbing.Hash{head.Hash()}
+		err = worktree.Reset(&gogit.ResetOptions{Commit: head.Hash(), Mode: gogit.MixedReset})
+	}
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to reset index: %w", err)
+	}
+
+	type block struct {
+		action, path string
+		matches      func(file string) bool
+	}
+	var blocks []block
+	for _, item := range items {
+		pattern := filepath.ToSlash(path.Clean(item.(map[string]interface{})["path"].(string)))
+		blocks = append(blocks, block{"add", pattern, func(file string) bool {
+			return file == pattern || strings.HasPrefix(file, pattern+"/")
+		}})
+	}
+	sort.SliceStable(blocks, func(i, j int) bool { return blocks[i].path < blocks[j].path })
+	for _, item := range removeItems {
+		pattern := filepath.ToSlash(path.Clean(item.(map[string]interface{})["path"].(string)))
+		blocks = append(blocks, block{"remove", pattern, func(file string) bool {
+			return matchesRemovePath(pattern, file)
+		}})
+	}
+
+	commit := func(files []string, message string) error {
+		for _, file := range files {
+			if _, err := worktree.Add(file); err != nil && !errors.Is(err, index.ErrEntryNotFound) {
+				return fmt.Errorf("failed to stage file %s: %w", file, err)
+			}
+		}
+		sha, err := createCommit(ctx, d, cfg, repo, worktree, message, parents)
+		if err != nil {
+			return err
+		}
+		parents = []plumbing.Hash{sha}
+		return nil
+	}
+
+	committed := make(map[string]bool)
+	commits := 0
+	for _, block := range blocks {
+		var files []string
+		for _, file := range changed {
+			if !committed[file] && block.matches(file) {
+				files = append(files, file)
+				committed[file] = true
+			}
+		}
+		if len(files) == 0 {
+			continue
+		}
+
+		if err := commit(files, splitMessage(d.Get("split_message").(string), message, block.action, block.path)); err != nil {
+			return plumbing.ZeroHash, err
+		}
+		commits++
+	}
+
+	// The other changes, e.g. moved files, are committed last
+	var rest []string
+	for _, file := range changed {
+		if !committed[file] {
+			rest = append(rest, file)
+		}
+	}
+	if len(rest) > 0 || commits == 0 {
+		if err := commit(rest, message); err != nil {
+			return plumbing.ZeroHash, err
+		}
+		commits++
+	}
+
+	tflog.Debug(ctx, "split changes into commits", map[string]interface{}{
+		"commits": commits,
+	})
+
+	return parents[0], nil
+}
+
+// splitMessage returns the template with its placeholders replaced.
+func splitMessage(template, message, action, path string) string {
+	return strings.NewReplacer("{message}", message, "{action}", action, "{path}", path).Replace(template)
+}
+
+// commitOptions returns the options used to create the commit for the
+// resource in repo.
+func commitOptions(d *schema.ResourceData, cfg *providerConfig, repo *gogit.Repository) (*gogit.CommitOptions, error) {
+	opts := &gogit.CommitOptions{
+		AllowEmptyCommits: d.Get("allow_empty").(bool),
+	}
+
+	// Reproducible commits leave out the identities read from the environment
+	// of the runner
+	reproducible := d.Get("reproducible").(bool)
+	when := time.Now()
+	author := cfg.author
+	if reproducible && cfg.envAuthor {
+		author = nil
+	}
+	if identity := expandIdentity(d.Get("author").([]interface{})); identity != nil {
+		author = identity
+	}
+	committer := cfg.committer
+	if reproducible && cfg.envCommitter {
+		committer = nil
+	}
+	if identity := expandIdentity(d.Get("committer").([]interface{})); identity != nil {
+		committer = identity
+	}
+
+	if reproducible {
+		var err error
+		when, err = time.Parse(time.RFC3339, d.Get("timestamp").(string))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse timestamp: %w", err)
+		}
+
+		// Both identities are set, so both get the fixed timestamp
+		if author == nil {
+			author = &reproducibleSignature
+		}
+		if committer == nil {
+			committer = author
+		}
+	}
+
+	if author != nil {
+		signature := *author
+		signature.When = when
+		opts.Author = &signature
+	}
+	if committer != nil {
+		signature := *committer
+		signature.When = when
+		opts.Committer = &signature
+	}
+
+	authorDate, committerDate := d.Get("author_date").(string), d.Get("committer_date").(string)
+	if authorDate != "" || committerDate != "" {
+		// Fill in the identities missing from the git configuration, so their
+		// dates can be set
+		if err := opts.Validate(repo); err != nil {
+			return nil, err
+		}
+		author, committer := *opts.Author, *opts.Committer
+
+		var err error
+		if authorDate != "" {
+			if author.When, err = time.Parse(time.RFC3339, authorDate); err != nil {
+				return nil, fmt.Errorf("failed to parse author_date: %w", err)
+			}
+		}
+		if committerDate != "" {
+			if committer.When, err = time.Parse(time.RFC3339, committerDate); err != nil {
+				return nil, fmt.Errorf("failed to parse committer_date: %w", err)
+			}
+		}
+		opts.Author, opts.Committer = &author, &committer
+	}
+
+	// A signing key of the resource replaces the one of the provider
+	if d.Get("ssh_signing_key_file").(string) == "" {
+		opts.SignKey = cfg.signKey
+	}
+	if path := d.Get("gpg_signing_key_file").(string); path != "" {
+		key, err := readSecret("gpg_signing_key", "", path)
+		if err != nil {
+			return nil, err
+		}
+		passphrase, err := readSecret("gpg_signing_key_passphrase", "", d.Get("gpg_signing_key_passphrase_file").(string))
+		if err != nil {
+			return nil, err
+		}
+		entity, err := readSigningKey(key, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		opts.SignKey = entity
+	}
+
+	return opts, nil
+}
+
+// The update strategies of git_commit.
+const (
+	updateStrategyCommit = "commit"
+	updateStrategyAmend  = "amend"
+)
+
+// The conflict strategies of the files of git_commit.
+const (
+	conflictStrategyFail      = "fail"
+	conflictStrategyOverwrite = "overwrite"
+	conflictStrategyMerge     = "merge"
+)
+
+// The line endings of add items.
+const (
+	eolLF   = "lf"
+	eolCRLF = "crlf"
+	eolAuto = "auto"
+)
+
+// skipCIMarker is recognized by GitHub Actions, GitLab CI, Bitbucket
+// Pipelines, Azure Pipelines and most other CI systems.
+const skipCIMarker = "[skip ci]"
+
+// commitMessage returns the full message of the commit for the resource,
+// created with opts.
+func commitMessage(d *schema.ResourceData, cfg *providerConfig, message string, opts *gogit.CommitOptions) string {
+	if d.Get("skip_ci").(bool) && !strings.Contains(message, skipCIMarker) {
+		subject, body, _ := strings.Cut(message, "\n")
+		message = strings.TrimRight(subject, " ") + " " + skipCIMarker
+		if body != "" {
+			message += "\n" + body
+		}
+	}
+
+	trailers := make(map[string]string, len(cfg.trailers))
+	for key, value := range cfg.trailers {
+		trailers[key] = value
+	}
+	for key, value := range d.Get("trailers").(map[string]interface{}) {
+		trailers[key] = value.(string)
+	}
+
+	var coAuthors []string
+	for _, item := range d.Get("co_authors").([]interface{}) {
+		coAuthor := item.(map[string]interface{})
+		coAuthors = append(coAuthors, fmt.Sprintf("%s <%s>", coAuthor["name"], coAuthor["email"]))
+	}
+
+	if _, ok := trailers["Change-Id"]; !ok && d.Get("add_change_id").(bool) && !changeIDPattern.MatchString(message) {
+		trailers["Change-Id"] = newChangeID(withTrailers(message, trailers, coAuthors), opts)
+	}
+
+	return withTrailers(message, trailers, coAuthors)
+}
+
+// shaPattern matches full SHA-1 and SHA-256 object names.
+var shaPattern = regexp.MustCompile(`^(?:[0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// remoteNamePattern matches the names of remotes.
+var remoteNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// remoteName returns the remote_name of the resource, origin by default.
+func remoteName(d *schema.ResourceData) string {
+	if name := d.Get("remote_name").(string); name != "" {
+		return name
+	}
+
+	return gogit.DefaultRemoteName
+}
+
+// changeIDPattern matches the Change-Id trailer of Gerrit.
+var changeIDPattern = regexp.MustCompile(`(?m)^Change-Id: (I[0-9a-f]{40})$`)
+
+// newChangeID returns a Gerrit Change-Id for a commit with the message and
+// opts, derived from them like the commit-msg hook of Gerrit does, so it only
+// depends on the inputs of reproducible commits.
+func newChangeID(message string, opts *gogit.CommitOptions) string {
+	hash := sha1.New()
+	for _, parent := range opts.Parents {
+		fmt.Fprintf(hash, "parent %s\n", parent)
+	}
+	for _, signature := range []*object.Signature{opts.Author, opts.Committer} {
+		if signature != nil {
+			fmt.Fprintf(hash, "%s %d\n", signature, signature.When.Unix())
+		}
+	}
+	fmt.Fprintf(hash, "\n%s", message)
+
+	return fmt.Sprintf("I%x", hash.Sum(nil))
+}
+
+// withTrailers appends trailers to a commit message, sorted by key, followed
+// by a Co-authored-by trailer for each of the co-authors.
+func withTrailers(message string, trailers map[string]string, coAuthors []string) string {
+	if len(trailers) == 0 && len(coAuthors) == 0 {
+		return message
+	}
+
+	keys := make([]string, 0, len(trailers))
+	for key := range trailers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys)+len(coAuthors))
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("%s: %s", key, trailers[key]))
+	}
+	for _, coAuthor := range coAuthors {
+		lines = append(lines, "Co-authored-by: "+coAuthor)
+	}
+
+	return strings.TrimRight(message, "\n") + "\n\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// errRefNotFound is returned when a ref does not exist on the remote.
+var errRefNotFound = errors.New("ref not found")
+
+// targetRefName is the local ref the target ref is fetched into.
+const targetRefName = plumbing.ReferenceName("refs/terraform/target")
+
+// resolveBase returns the commit a resource builds on: the tip of the target
+// ref if it is set and exists on the remote remoteName, otherwise the tip of
+// the branch. errRefNotFound is returned if the target ref does not exist,
+// along with the tip of the branch.
+func resolveBase(ctx context.Context, repo *gogit.Repository, remoteName, branch, targetRef string, auth transport.AuthMethod) (*plumbing.Hash, error) {
+	var targetErr error
+	if targetRef != "" {
+		err := repo.FetchContext(ctx, &gogit.FetchOptions{
+			RemoteName: remoteName,
+			RefSpecs: []config.RefSpec{
+				config.RefSpec(fmt.Sprintf("+%s:%s", targetRef, targetRefName)),
+			},
+			Auth: auth,
+		})
+		var noMatchErr gogit.NoMatchingRefSpecError
+		if errors.As(err, &noMatchErr) {
+			targetErr = fmt.Errorf("target ref %s: %w", targetRef, errRefNotFound)
+		} else if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+			return nil, fmt.Errorf("failed to fetch target ref %s: %w", targetRef, err)
+		} else {
+			sha, err := repo.ResolveRevision(plumbing.Revision(targetRefName))
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve target ref %s: %w", targetRef, err)
+			}
+			return sha, nil
+		}
+	}
+
+	sha, err := repo.ResolveRevision(plumbing.Revision(plumbing.NewRemoteReferenceName(remoteName, branch)))
+	if err != nil && errors.Is(err, plumbing.ErrReferenceNotFound) {
+		sha, err = repo.ResolveRevision(plumbing.Revision(plumbing.NewBranchReferenceName(branch)))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve branch %s: %w", branch, err)
+	}
+
+	return sha, targetErr
+}
+
+// resolveCommitBase resolves the commit the resource builds on like
+// resolveBase. When the branch does not exist and create_branch is set, the
+// branch is created from base_ref instead, and when orphan is set or the
+// repository is empty it is created without history, which is returned as a
+// nil sha.
+func resolveCommitBase(ctx context.Context, d *schema.ResourceData, repo *gogit.Repository, auth transport.AuthMethod) (*plumbing.Hash, error) {
+	sha, err := resolveBase(ctx, repo, remoteName(d), d.Get("branch").(string), d.Get("target_ref").(string), auth)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		empty, emptyErr := isEmptyRepository(repo)
+		if emptyErr != nil {
+			return nil, emptyErr
+		}
+		if empty || d.Get("orphan").(bool) {
+			return nil, nil
+		}
+	}
+	if !errors.Is(err, plumbing.ErrReferenceNotFound) || !d.Get("create_branch").(bool) {
+		return sha, err
+	}
+
+	baseRef := d.Get("base_ref").(string)
+	if baseRef == "" {
+		// The clone checks out the default branch
+		sha, err := repo.ResolveRevision(plumbing.Revision(plumbing.HEAD))
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the default branch: %w", err)
+		}
+		return sha, nil
+	}
+
+	return resolveRef(repo, remoteName(d), baseRef)
+}
+
+// checkoutOrphan points HEAD to the unborn branch and empties the index and
+// the worktree, so that the next commit starts the branch without history.
+func checkoutOrphan(repo *gogit.Repository, worktree *gogit.Worktree, branch string) error {
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName(branch))
+	if err := repo.Storer.SetReference(head); err != nil {
+		return err
+	}
+	if err := repo.Storer.SetIndex(&index.Index{Version: 2}); err != nil {
+		return err
+	}
+
+	return emptyWorktree(worktree)
+}
+
+// cloneCommitRepository clones the repository of the resource into memory,
+// or initializes it if it is empty and init is set, and fetches the branch
+// from push_url, so commits build on the branch they are pushed to.
+func cloneCommitRepository(ctx context.Context, d *schema.ResourceData, cfg *providerConfig, init bool) (*gogit.Repository, diag.Diagnostics) {
+	clone := cfg.clone
+	if init {
+		clone = cfg.cloneOrInit
+	}
+	repo, err := clone(ctx, d.Get("url").(string), remoteName(d), memfs.New())
+	if err != nil {
+		return nil, diag.Errorf("failed to clone repository: %s", err)
+	}
+
+	branch := d.Get("branch").(string)
+	if err := fetchPushBranch(ctx, cfg, repo, remoteName(d), d.Get("push_url").(string), branch); err != nil {
+		return nil, diag.Errorf("failed to fetch branch %s from push_url: %s", branch, err)
+	}
+
+	return repo, nil
+}
+
+// fetchPushBranch fetches the branch from pushURL, if it is not empty, over
+// the branch fetched from the remote remoteName, so commits build on the
+// branch they are pushed to even when the remote is a mirror lagging behind
+// it.
+func fetchPushBranch(ctx context.Context, cfg *providerConfig, repo *gogit.Repository, remoteName, pushURL, branch string) error {
+	if pushURL == "" {
+		return nil
+	}
+
+	pushURL, auth, err := cfg.resolveRemote(ctx, cfg.rewriteURL(pushURL))
+	if err != nil {
+		return err
+	}
+
+	err = repo.FetchContext(ctx, &gogit.FetchOptions{
+		RemoteName: remoteName,
+		RemoteURL:  pushURL,
 		RefSpecs: []config.RefSpec{
-			config.RefSpec(fmt.Sprintf("%s:%s", branchRef, branchRef)),
+			config.RefSpec(fmt.Sprintf("+%s:%s", plumbing.NewBranchReferenceName(branch), plumbing.NewRemoteReferenceName(remoteName, branch))),
 		},
 		Auth: auth,
 	})
-	if err != nil {
-		return diag.Errorf("failed to push: %s", err)
+	var noMatchErr gogit.NoMatchingRefSpecError
+	if errors.As(err, &noMatchErr) || errors.Is(err, gogit.NoErrAlreadyUpToDate) || errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return nil
 	}
 
-	return nil
+	return err
+}
+
+// pushRef returns the remote ref a commit is pushed to.
+func pushRef(branch, targetRef string) plumbing.ReferenceName {
+	if targetRef != "" {
+		return plumbing.ReferenceName(targetRef)
+	}
+
+	return plumbing.NewBranchReferenceName(branch)
+}
+
+// pushBranchOptions are the options of pushBranch.
+type pushBranchOptions struct {
+	// remoteName is the name of the remote of the repository.
+	remoteName string
+	// pushURL is the URL pushed to instead of the remote, if not empty.
+	pushURL string
+	// branchRef is the local branch pushed to remoteRef. When empty, only
+	// the tags are pushed.
+	branchRef plumbing.ReferenceName
+	remoteRef plumbing.ReferenceName
+	// tags are pushed along with the branch.
+	tags []plumbing.ReferenceName
+	auth transport.AuthMethod
+	// options are the push options sent to the remote if it supports them.
+	options map[string]string
+	// lease, when not nil, is the commit the remote ref must still point to
+	// for it to be force updated.
+	lease *plumbing.Hash
+}
+
+// pushBranch pushes the local branch to the remote ref of the remote, or of
+// the push URL, with its tags, and returns the messages sent by the remote.
+// When the push fails, the messages (such as the output of pre-receive hooks)
+// are included in the diagnostic, as they usually explain why the push was
+// rejected. It also reports whether the push was rejected because the remote
+// ref was updated since it was read, so the changes can be applied again on
+// top of it.
+func pushBranch(ctx context.Context, cfg *providerConfig, repo *gogit.Repository, push pushBranchOptions) ([]string, bool, diag.Diagnostics) {
+	remoteName, pushURL, auth := push.remoteName, push.pushURL, push.auth
+	branchRef, remoteRef, tags, lease := push.branchRef, push.remoteRef, push.tags, push.lease
+	if pushURL != "" {
+		var err error
+		pushURL, auth, err = cfg.resolveRemote(ctx, cfg.rewriteURL(pushURL))
+		if err != nil {
+			return nil, false, diag.FromErr(err)
+		}
+	}
+
+	var progress bytes.Buffer
+
+	opts := &gogit.PushOptions{
+		RemoteName: remoteName,
+		Auth:       auth,
+		Progress:   &progress,
+		RemoteURL:  pushURL,
+		Options:    push.options,
+	}
+	if branchRef != "" {
+		opts.RefSpecs = append(opts.RefSpecs, config.RefSpec(fmt.Sprintf("%s:%s", branchRef, remoteRef)))
+	}
+	for _, tag := range tags {
+		opts.RefSpecs = append(opts.RefSpecs, config.RefSpec(fmt.Sprintf("%s:%s", tag, tag)))
+	}
+	if len(tags) > 0 {
+		if diags := checkTags(ctx, repo, remoteName, pushURL, tags, auth); diags.HasError() {
+			return nil, false, diags
+		}
+
+		// The branch is not updated without its tags, if the remote supports it
+		opts.Atomic = true
+	}
+	if lease != nil {
+		// The lease is checked against the refs advertised for the push, which
+		// the remote only updates if they did not change meanwhile
+		opts.ForceWithLease = &gogit.ForceWithLease{
+			RefName: remoteRef,
+			Hash:    *lease,
+		}
+	}
+
+	err := repo.PushContext(ctx, opts)
+	messages := remoteMessages(progress.String())
+	if err != nil {
+		rejected := branchRef != "" && isRejectedPush(ctx, repo, remoteName, pushURL, branchRef, remoteRef, auth, lease, err)
+		if lease != nil && rejected {
+			// Explain that the force push was aborted rather than report a
+			// non-fast-forward update
+			if diags := checkLease(ctx, repo, remoteName, pushURL, remoteRef, auth, *lease,
+				"The force push was aborted so that commits pushed since are not lost. Apply again to commit on top of it."); diags.HasError() {
+				return messages, true, diags
+			}
+		}
+
+		var detail []string
+		for _, message := range messages {
+			detail = append(detail, "remote: "+message)
+		}
+
+		return messages, rejected, diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("failed to push: %s", err),
+				Detail:   strings.Join(detail, "\n"),
+			},
+		}
+	}
+
+	return messages, false, nil
+}
+
+// checkTags returns an error diagnostic if one of the tags already exists in
+// the remote remoteName, or pushURL if it is not empty, as tags are never
+// moved.
+func checkTags(ctx context.Context, repo *gogit.Repository, remoteName, pushURL string, tags []plumbing.ReferenceName, auth transport.AuthMethod) diag.Diagnostics {
+	pushURL, err := remotePushURL(repo, remoteName, pushURL)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	refs, err := remoteRefs(ctx, pushURL, remoteName, auth)
+	if err != nil {
+		return diag.Errorf("failed to read tags: %s", err)
+	}
+	for _, tag := range tags {
+		if sha, ok := refs[tag]; ok {
+			return diag.Errorf("failed to push: tag %s already exists and points to %s", tag.Short(), sha)
+		}
+	}
+
+	return nil
+}
+
+// remotePushURL returns pushURL, or the URL of the remote remoteName if it is
+// empty.
+func remotePushURL(repo *gogit.Repository, remoteName, pushURL string) (string, error) {
+	if pushURL != "" {
+		return pushURL, nil
+	}
+
+	remote, err := repo.Remote(remoteName)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve remote: %w", err)
+	}
+
+	return remote.Config().URLs[0], nil
+}
+
+// previousTagNames returns the names of the tags the resource created with
+// previous commits.
+func previousTagNames(d *schema.ResourceData) map[string]bool {
+	previousTags := make(map[string]bool)
+	oldTags, _ := d.GetChange("tags")
+	for _, item := range oldTags.([]interface{}) {
+		previousTags[item.(map[string]interface{})["name"].(string)] = true
+	}
+
+	return previousTags
+}
+
+// pushExistingCommitTags creates the tags of the resource, except the ones in
+// skip, pointing to sha, the existing commit used when the files are
+// unchanged, and pushes them without the branch.
+func pushExistingCommitTags(ctx context.Context, d *schema.ResourceData, cfg *providerConfig, repo *gogit.Repository, sha plumbing.Hash, auth transport.AuthMethod, skip map[string]bool) diag.Diagnostics {
+	tags, err := createTags(repo, d.Get("tags").([]interface{}), sha, skip)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	_, _, diags := pushBranch(ctx, cfg, repo, pushBranchOptions{
+		remoteName: remoteName(d),
+		pushURL:    d.Get("push_url").(string),
+		tags:       tags,
+		auth:       auth,
+		options:    pushOptions(d),
+	})
+	return diags
+}
+
+// createTags creates the tags of the resource pointing to sha, except the
+// ones in skip, and returns their refs. Tags with a message are annotated,
+// with the committer of the commit as tagger.
+func createTags(repo *gogit.Repository, items []interface{}, sha plumbing.Hash, skip map[string]bool) ([]plumbing.ReferenceName, error) {
+	commit, err := repo.CommitObject(sha)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit %s: %w", sha, err)
+	}
+
+	var refs []plumbing.ReferenceName
+	for _, item := range items {
+		name := item.(map[string]interface{})["name"].(string)
+		message := item.(map[string]interface{})["message"].(string)
+		if skip[name] {
+			continue
+		}
+
+		var opts *gogit.CreateTagOptions
+		if message != "" {
+			opts = &gogit.CreateTagOptions{
+				Tagger:  &commit.Committer,
+				Message: message,
+			}
+		}
+
+		ref, err := repo.CreateTag(name, sha, opts)
+		if errors.Is(err, gogit.ErrTagExists) {
+			existing, _ := repo.Tag(name)
+			return nil, fmt.Errorf("tag %s already exists and points to %s", name, existing.Hash())
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to create tag %s: %w", name, err)
+		}
+		refs = append(refs, ref.Name())
+	}
+
+	return refs, nil
+}
+
+// pushOptions returns the push options of the resource, given as key=value
+// or key like git push -o. go-git sends options without a value as key=,
+// which servers such as GitLab treat as set.
+func pushOptions(d *schema.ResourceData) map[string]string {
+	options := make(map[string]string)
+	for _, option := range d.Get("push_options").([]interface{}) {
+		key, value, _ := strings.Cut(option.(string), "=")
+		options[key] = value
+	}
+
+	return options
+}
+
+// isRejectedPush reports whether the push of branchRef to the remote ref of
+// the remote remoteName, or of pushURL if it is not empty, failed with err because the remote
+// ref was updated since it was read, so the changes can be applied again on
+// top of it. Rather than matching the messages of go-git and of the remote,
+// the remote ref is read again: the push was rejected if the ref no longer
+// points to lease, or, without lease, to a commit of the local branch.
+func isRejectedPush(ctx context.Context, repo *gogit.Repository, remoteName, pushURL string, branchRef, remoteRef plumbing.ReferenceName, auth transport.AuthMethod, lease *plumbing.Hash, err error) bool {
+	if errors.Is(err, gogit.ErrNonFastForwardUpdate) || errors.Is(err, gogit.ErrForceNeeded) {
+		return true
+	}
+
+	pushURL, urlErr := remotePushURL(repo, remoteName, pushURL)
+	if urlErr != nil {
+		return false
+	}
+	refs, refsErr := remoteRefs(ctx, pushURL, remoteName, auth)
+	if refsErr != nil {
+		return false
+	}
+	current, ok := refs[remoteRef]
+	if lease != nil {
+		return current != *lease
+	}
+	if !ok {
+		return false
+	}
+
+	head, headErr := repo.Reference(branchRef, true)
+	if headErr != nil {
+		return false
+	}
+	headCommit, headErr := repo.CommitObject(head.Hash())
+	if headErr != nil {
+		return false
+	}
+	// Commits pushed since the clone are unknown to the repository
+	commit, commitErr := repo.CommitObject(current)
+	if commitErr != nil {
+		return true
+	}
+	ancestor, ancestorErr := commit.IsAncestor(headCommit)
+	return ancestorErr == nil && !ancestor
+}
+
+// commitFunc clones the repository, commits the changes of the resource and
+// pushes them, reporting whether the push was rejected because the branch was
+// updated meanwhile.
+type commitFunc func(ctx context.Context, d *schema.ResourceData, cfg *providerConfig, auth transport.AuthMethod) (bool, diag.Diagnostics)
+
+// pushWithRetries runs commit again, from a new clone, as long as its push is
+// rejected because the branch was updated meanwhile, e.g. by a concurrent
+// pipeline, up to push_retries times.
+func pushWithRetries(ctx context.Context, d *schema.ResourceData, cfg *providerConfig, auth transport.AuthMethod, commit commitFunc) diag.Diagnostics {
+	retries := d.Get("push_retries").(int)
+	for attempt := 1; ; attempt++ {
+		rejected, diags := commit(ctx, d, cfg, auth)
+		if !rejected || attempt > retries {
+			return diags
+		}
+
+		tflog.Info(ctx, "push rejected as the branch was updated, applying the changes again", map[string]interface{}{
+			"attempt": attempt,
+			"retries": retries,
+		})
+
+		// Waiting a random delay keeps concurrent applies from racing again
+		select {
+		case <-ctx.Done():
+			return diags
+		case <-time.After(time.Duration(rand.Int63n(int64(attempt) * int64(time.Second)))):
+		}
+	}
+}
+
+// checkLease returns an error diagnostic with detail unless the remote ref of
+// the remote remoteName, or of pushURL if it is not empty, still points to
+// lease, the commit it was expected to point to.
+func checkLease(ctx context.Context, repo *gogit.Repository, remoteName, pushURL string, remoteRef plumbing.ReferenceName, auth transport.AuthMethod, lease plumbing.Hash, detail string) diag.Diagnostics {
+	pushURL, err := remotePushURL(repo, remoteName, pushURL)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	refs, err := remoteRefs(ctx, pushURL, remoteName, auth)
+	if err != nil {
+		return diag.Errorf("failed to read %s: %s", remoteRef, err)
+	}
+	current, ok := refs[remoteRef]
+	if !ok {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("failed to push: %s does not exist, expected it to point to %s", remoteRef, lease),
+				Detail:   detail,
+			},
+		}
+	}
+	if current != lease {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("failed to push: %s points to %s, expected %s", remoteRef, current, lease),
+				Detail:   detail,
+			},
+		}
+	}
+
+	return nil
+}
+
+// checkExpectedParent returns an error diagnostic unless the remote ref of
+// the remote remoteName, or of pushURL if it is not empty, points to
+// expected, the expected_parent_sha of the resource. The push that follows is
+// not forced, so the remote refuses it if the ref is updated meanwhile.
+func checkExpectedParent(ctx context.Context, cfg *providerConfig, repo *gogit.Repository, remoteName, pushURL string, remoteRef plumbing.ReferenceName, auth transport.AuthMethod, expected plumbing.Hash) diag.Diagnostics {
+	if pushURL != "" {
+		var err error
+		pushURL, auth, err = cfg.resolveRemote(ctx, cfg.rewriteURL(pushURL))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	return checkLease(ctx, repo, remoteName, pushURL, remoteRef, auth, expected,
+		fmt.Sprintf("%s was updated since expected_parent_sha was set. Set it to the commit %s points to, to commit on top of it.", remoteRef, remoteRef))
+}
+
+// remoteMessages splits the sideband output of the remote into lines. Progress
+// lines that were overwritten with a carriage return are collapsed to their
+// final state.
+func remoteMessages(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if i := strings.LastIndex(strings.TrimRight(line, "\r"), "\r"); i >= 0 {
+			line = line[i+1:]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// urlPattern matches the URLs in remote messages, e.g. the link to create a
+// pull request.
+var urlPattern = regexp.MustCompile(`https?://[^\s]+`)
+
+// setPushOutput sets the computed attributes holding the remote messages of a
+// push.
+func setPushOutput(d *schema.ResourceData, messages []string) diag.Diagnostics {
+	urls := []string{}
+	for _, message := range messages {
+		urls = append(urls, urlPattern.FindAllString(message, -1)...)
+	}
+
+	if err := d.Set("push_messages", messages); err != nil {
+		return diag.Errorf("failed to set push_messages: %s", err)
+	}
+	if err := d.Set("push_urls", urls); err != nil {
+		return diag.Errorf("failed to set push_urls: %s", err)
+	}
+
+	return nil
+}
+
+// conventionalCommitPattern matches the header of a Conventional Commits
+// message: a type, an optional scope, an optional breaking change marker and a
+// description.
+var conventionalCommitPattern = regexp.MustCompile(`^[a-zA-Z]+(\([^()\r\n]+\))?!?: [^\r\n]+`)
+
+// validateCommitMessages checks the commit messages against the conventional
+// commits format and message pattern when they are set.
+func validateCommitMessages(d *schema.ResourceDiff) error {
+	var patterns []*regexp.Regexp
+	if d.Get("conventional_commits").(bool) {
+		patterns = append(patterns, conventionalCommitPattern)
+	}
+	if pattern, ok := d.GetOk("message_pattern"); ok {
+		re, err := regexp.Compile(pattern.(string))
+		if err != nil {
+			return fmt.Errorf("invalid message_pattern: %s", err)
+		}
+		patterns = append(patterns, re)
+	}
+
+	messages := make(map[string]string)
+	for _, key := range []string{"message", "update_message", "delete_message"} {
+		if message, ok := d.GetOk(key); ok && d.NewValueKnown(key) {
+			messages[key] = message.(string)
+		}
+	}
+	if message, ok := messages["message"]; ok && d.Get("split_commits").(bool) && d.NewValueKnown("split_message") {
+		messages["split_message"] = splitMessage(d.Get("split_message").(string), message, "add", "file")
+	}
+
+	for _, key := range []string{"message", "update_message", "delete_message", "split_message"} {
+		message, ok := messages[key]
+		if !ok {
+			continue
+		}
+
+		for _, re := range patterns {
+			if !re.MatchString(message) {
+				if re == conventionalCommitPattern {
+					return fmt.Errorf("%s %q does not follow the Conventional Commits format", key, message)
+				}
+				return fmt.Errorf("%s %q does not match message_pattern %s", key, message, re)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateRepoPath ensures a path refers to a file inside the worktree.
+func validateRepoPath(i interface{}, k string) ([]string, []error) {
+	v, ok := i.(string)
+	if !ok {
+		return nil, []error{fmt.Errorf("expected type of %s to be string", k)}
+	}
+
+	if v == "" {
+		return nil, []error{fmt.Errorf("expected %s to not be empty", k)}
+	}
+	if strings.HasPrefix(v, "/") || strings.HasPrefix(v, "\\") || filepath.IsAbs(v) || hasDriveLetter(v) {
+		return nil, []error{fmt.Errorf("expected %s to be a path relative to the repository root, got %s", k, v)}
+	}
+
+	for _, segment := range strings.FieldsFunc(v, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if segment == ".." {
+			return nil, []error{fmt.Errorf("expected %s to not contain '..', got %s", k, v)}
+		}
+		if strings.EqualFold(segment, ".git") {
+			return nil, []error{fmt.Errorf("expected %s to not be inside a .git directory, got %s", k, v)}
+		}
+	}
+
+	return nil, nil
+}
+
+// hasDriveLetter reports whether a path starts with a Windows drive letter,
+// regardless of the platform the provider is running on.
+func hasDriveLetter(v string) bool {
+	return len(v) >= 2 && v[1] == ':' && (('a' <= v[0] && v[0] <= 'z') || ('A' <= v[0] && v[0] <= 'Z'))
+}
+
+// writeFiles writes the content of the add items to the worktree.
+func writeFiles(worktree *gogit.Worktree, items []interface{}) diag.Diagnostics {
+	for _, item := range items {
+		path := item.(map[string]interface{})["path"].(string)
+		ignoreWhitespace := item.(map[string]interface{})["ignore_whitespace"].(bool)
+		executable := item.(map[string]interface{})["executable"].(bool)
+
+		path = worktree.Filesystem.Join(path)
+
+		// Leave existing files untouched
+		if item.(map[string]interface{})["create_only"].(bool) {
+			if _, err := worktree.Filesystem.Lstat(path); err == nil {
+				continue
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return diag.Errorf("failed to read file %s: %s", path, err)
+			}
+		}
+
+		// Leave the file untouched if it only differs in whitespace
+		if ignoreWhitespace {
+			content, err := addContent(item.(map[string]interface{}))
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			existing, err := util.ReadFile(worktree.Filesystem, path)
+			if err == nil && collapseWhitespace(string(existing)) == collapseWhitespace(string(content)) && (!executable || isExecutable(worktree, path)) {
+				continue
+			}
+		}
+
+		// Leave the file untouched if it holds the same data
+		if format := item.(map[string]interface{})["format"].(string); format != "" {
+			content, err := addContent(item.(map[string]interface{}))
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			existing, err := util.ReadFile(worktree.Filesystem, path)
+			if err == nil && semanticallyEqual(existing, content, format) && (!executable || isExecutable(worktree, path)) {
+				continue
+			}
+		}
+
+		var content io.ReadCloser
+		var err error
+		if item.(map[string]interface{})["append"].(bool) {
+			// Leave the file untouched if it already contains the content
+			appended, ok, err := appendContent(worktree, path, item.(map[string]interface{}))
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			if !ok {
+				continue
+			}
+			content = io.NopCloser(bytes.NewReader(appended))
+		} else if item.(map[string]interface{})["merge"].(bool) {
+			// Leave the file untouched if it already holds the data
+			merged, ok, err := deepMergeContent(worktree, path, item.(map[string]interface{}))
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			if !ok {
+				continue
+			}
+			content = io.NopCloser(bytes.NewReader(merged))
+		} else if eol := item.(map[string]interface{})["eol"].(string); eol != "" {
+			converted, err := addContent(item.(map[string]interface{}))
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			existing, _ := util.ReadFile(worktree.Filesystem, path)
+			content = io.NopCloser(bytes.NewReader(convertEOL(converted, eol, existing)))
+		} else {
+			content, err = openAddContent(item.(map[string]interface{}))
+			if err != nil {
+				return diag.FromErr(err)
+			}
+		}
+
+		// Create, write then close file
+		var file billy.File
+		if executable {
+			// Existing files keep their mode when opened, so they are replaced
+			if err := worktree.Filesystem.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				content.Close()
+				return diag.Errorf("failed to replace file %s: %s", path, err)
+			}
+			file, err = worktree.Filesystem.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
+		} else {
+			file, err = worktree.Filesystem.Create(path)
+		}
+		if err != nil {
+			content.Close()
+			return diag.Errorf("failed to create file %s: %s", path, err)
+		}
+
+		_, err = io.Copy(file, content)
+		content.Close()
+		if err != nil {
+			return diag.Errorf("failed to write to file %s: %s", path, err)
+		}
+
+		err = file.Close()
+		if err != nil {
+			return diag.Errorf("failed to close file %s: %s", path, err)
+		}
+	}
+
+	return nil
+}
+
+// addContent returns the content of an add item, decoded from content_base64
+// or read from source_file when they are set.
+func addContent(item map[string]interface{}) ([]byte, error) {
+	reader, err := openAddContent(item)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source_file of %s: %w", item["path"], err)
+	}
+
+	return content, nil
+}
+
+// openAddContent returns a reader of the content of an add item, streaming
+// source_file rather than reading it in memory unless a trailing newline must
+// be ensured.
+func openAddContent(item map[string]interface{}) (io.ReadCloser, error) {
+	reader, err := openSourceContent(item)
+	if err != nil || !item["ensure_trailing_newline"].(bool) {
+		return reader, err
+	}
+	defer reader.Close()
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source_file of %s: %w", item["path"], err)
+	}
+
+	return io.NopCloser(bytes.NewReader(ensureTrailingNewline(content))), nil
+}
+
+// openSourceContent returns a reader of the content of an add item as it is
+// set: content, content_base64 or source_file.
+func openSourceContent(item map[string]interface{}) (io.ReadCloser, error) {
+	if source := item["source_file"].(string); source != "" {
+		file, err := os.Open(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open source_file of %s: %w", item["path"], err)
+		}
+		return file, nil
+	}
+
+	if encoded := item["content_base64"].(string); encoded != "" {
+		content, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode content_base64 of %s: %w", item["path"], err)
+		}
+		return io.NopCloser(bytes.NewReader(content)), nil
+	}
+
+	return io.NopCloser(strings.NewReader(item["content"].(string))), nil
+}
+
+// appendContent returns the content of the file at path in worktree with the
+// content of the add item appended, on a new line. It returns false if the
+// file already contains it as whole lines.
+func appendContent(worktree *gogit.Worktree, path string, item map[string]interface{}) ([]byte, bool, error) {
+	content, err := addContent(item)
+	if err != nil {
+		return nil, false, err
+	}
+
+	eol := item["eol"].(string)
+	existing, err := util.ReadFile(worktree.Filesystem, path)
+	if errors.Is(err, os.ErrNotExist) {
+		return convertEOL(content, eol, nil), true, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	if lineBlockIndex(existing, convertEOL(content, eol, existing)) >= 0 {
+		return nil, false, nil
+	}
+
+	if len(existing) > 0 && !bytes.HasSuffix(existing, []byte("\n")) {
+		existing = append(existing, '\n')
+	}
+
+	return convertEOL(append(existing, content...), eol, existing), true, nil
+}
+
+// pruneFile removes the file of an add item from worktree or, for an item
+// appending or merging its content, removes the content from the file, and
+// the file once it is empty.
+func pruneFile(worktree *gogit.Worktree, item map[string]interface{}) error {
+	path := worktree.Filesystem.Join(item["path"].(string))
+	if item["merge"].(bool) {
+		info, err := worktree.Filesystem.Lstat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", path, err)
+		}
+		pruned, ok, err := unmergeContent(worktree, path, item)
+		if err != nil {
+			return err
+		}
+		if ok {
+			// Keep the mode of the file, e.g. executable scripts
+			if err := util.WriteFile(worktree.Filesystem, path, pruned, info.Mode().Perm()); err != nil {
+				return fmt.Errorf("failed to write file %s: %w", path, err)
+			}
+			return nil
+		}
+	}
+	if !item["append"].(bool) {
+		_, err := worktree.Remove(path)
+		if err != nil && !errors.Is(err, index.ErrEntryNotFound) {
+			return fmt.Errorf("failed to delete file %s: %w", path, err)
+		}
+		return nil
+	}
+
+	content, err := addContent(item)
+	if err != nil {
+		return err
+	}
+	info, err := worktree.Filesystem.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	existing, err := util.ReadFile(worktree.Filesystem, path)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	if pruned, ok := removeLineBlock(existing, convertEOL(content, item["eol"].(string), existing)); ok {
+		if err := util.WriteFile(worktree.Filesystem, path, pruned, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to write file %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// lineBlockIndex returns the index of the first occurrence of block in
+// content that starts and ends on line boundaries, so a block is not found
+// in the middle of a line, or -1 if there is none. An empty block is found at
+// the start of content.
+func lineBlockIndex(content, block []byte) int {
+	if len(block) == 0 {
+		return 0
+	}
+
+	for offset := 0; offset < len(content); {
+		i := bytes.Index(content[offset:], block)
+		if i < 0 {
+			return -1
+		}
+		start, end := offset+i, offset+i+len(block)
+		startsLine := start == 0 || content[start-1] == '\n'
+		endsLine := block[len(block)-1] == '\n' || end == len(content) || content[end] == '\n' || bytes.HasPrefix(content[end:], []byte("\r\n"))
+		if startsLine && endsLine {
+			return start
+		}
+		offset = start + 1
+	}
+
+	return -1
+}
+
+// removeLineBlock returns content without the first occurrence of block on
+// line boundaries and the line break separating it from the other lines, the
+// one appendContent inserts before a block at the end of a file. It returns
+// false if content does not contain block.
+func removeLineBlock(content, block []byte) ([]byte, bool) {
+	start := lineBlockIndex(content, block)
+	if len(block) == 0 || start < 0 {
+		return nil, false
+	}
+	end := start + len(block)
+
+	if block[len(block)-1] != '\n' {
+		switch {
+		case bytes.HasPrefix(content[end:], []byte("\r\n")):
+			end += 2
+		case end < len(content):
+			end++
+		case bytes.HasSuffix(content[:start], []byte("\r\n")):
+			start -= 2
+		case start > 0:
+			start--
+		}
+	}
+
+	pruned := append([]byte{}, content[:start]...)
+	return append(pruned, content[end:]...), true
+}
+
+// moveFiles moves the files or directories of the move items, replacing
+// their destination and skipping the ones that no longer exist.
+func moveFiles(worktree *gogit.Worktree, items []interface{}) diag.Diagnostics {
+	for _, item := range items {
+		from := worktree.Filesystem.Join(item.(map[string]interface{})["from"].(string))
+		to := worktree.Filesystem.Join(item.(map[string]interface{})["to"].(string))
+
+		if _, err := worktree.Filesystem.Lstat(from); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		// Like git mv -f, an existing destination is replaced
+		if err := util.RemoveAll(worktree.Filesystem, to); err != nil {
+			return diag.Errorf("failed to replace %s: %s", to, err)
+		}
+		if err := moveTree(worktree.Filesystem, from, to); err != nil {
+			return diag.Errorf("failed to move %s to %s: %s", from, to, err)
+		}
+	}
+
+	return nil
+}
+
+// moveTree moves the file or directory from to to. The files are moved one by
+// one, as renaming a directory of memfs loses its nested directories, and
+// renaming a file also renames the ones whose path starts with its path.
+func moveTree(fs billy.Filesystem, from, to string) error {
+	var files []string
+	err := util.Walk(fs, from, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		target := to
+		if file != from {
+			rel := strings.TrimPrefix(filepath.ToSlash(file), filepath.ToSlash(from)+"/")
+			target = fs.Join(to, rel)
+		}
+		if err := fs.MkdirAll(path.Dir(filepath.ToSlash(target)), 0755); err != nil {
+			return err
+		}
+
+		info, err := fs.Lstat(file)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err := fs.Readlink(file)
+			if err != nil {
+				return err
+			}
+			err = fs.Symlink(link, target)
+			if err != nil {
+				return err
+			}
+		} else {
+			content, err := util.ReadFile(fs, file)
+			if err != nil {
+				return err
+			}
+			err = util.WriteFile(fs, target, content, info.Mode().Perm())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return util.RemoveAll(fs, from)
+}
+
+// stageWorktree stages the changes of worktree, or only the ones to the
+// managed paths. Files ignored by the .gitignore files of the repository are
+// left out unless they are not respected, then the ignored files of the
+// managed paths are staged as well.
+func stageWorktree(worktree *gogit.Worktree, paths []string, managedOnly, respectGitignore bool) error {
+	if !managedOnly {
+		err := worktree.AddWithOptions(&gogit.AddOptions{
+			All: true,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to stage worktree: %w", err)
+		}
+		if respectGitignore {
+			return nil
+		}
+	}
+
+	// Ignored files are left out of the status
+	status, err := worktree.Status()
+	if err != nil {
+		return fmt.Errorf("failed to compute worktree status: %w", err)
+	}
+	for _, file := range paths {
+		// Changes to the files of directories, e.g. moved ones, are staged too
+		var changed []string
+		for name := range status {
+			if name == file || strings.HasPrefix(name, file+"/") {
+				changed = append(changed, name)
+			}
+		}
+		if len(changed) == 0 && !respectGitignore {
+			if info, err := worktree.Filesystem.Lstat(file); err == nil && !info.IsDir() {
+				changed = append(changed, file)
+			}
+		}
+
+		for _, name := range changed {
+			if _, err := worktree.Add(name); err != nil && !errors.Is(err, index.ErrEntryNotFound) {
+				return fmt.Errorf("failed to stage file %s: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// managedPaths returns the paths of the add items and of the move, yaml_set,
+// json_patch and kustomize_image blocks of the resource.
+func managedPaths(d *schema.ResourceData, items []interface{}) []string {
+	var paths []string
+	for _, item := range items {
+		paths = append(paths, item.(map[string]interface{})["path"].(string))
+	}
+	for _, item := range d.Get("move").([]interface{}) {
+		paths = append(paths, item.(map[string]interface{})["from"].(string), item.(map[string]interface{})["to"].(string))
+	}
+	for _, key := range []string{"yaml_set", "json_patch", "kustomize_image"} {
+		for _, item := range d.Get(key).([]interface{}) {
+			paths = append(paths, item.(map[string]interface{})["path"].(string))
+		}
+	}
+
+	for i, file := range paths {
+		paths[i] = filepath.ToSlash(path.Clean(file))
+	}
+
+	return paths
+}
+
+// removeFiles removes the files matching the paths of the remove items: a
+// file, every file of a directory, or the files matching a pattern with the
+// syntax of .gitignore. Paths matching no file are skipped, as they were
+// removed by a previous apply. It returns the number of files removed.
+func removeFiles(ctx context.Context, worktree *gogit.Worktree, items []interface{}) (int, diag.Diagnostics) {
+	removed := 0
+	for _, item := range items {
+		pattern := filepath.ToSlash(path.Clean(item.(map[string]interface{})["path"].(string)))
+
+		var files []string
+		err := util.Walk(worktree.Filesystem, "/", func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if info.Name() == gogit.GitDirName {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			file = strings.TrimPrefix(filepath.ToSlash(file), "/")
+			if matchesRemovePath(pattern, file) {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return removed, diag.Errorf("failed to list files matching %s: %s", pattern, err)
+		}
+
+		for _, file := range files {
+			if _, err := worktree.Remove(file); err != nil && !errors.Is(err, index.ErrEntryNotFound) {
+				return removed, diag.Errorf("failed to remove file %s: %s", file, err)
+			}
+		}
+		removed += len(files)
+
+		tflog.Debug(ctx, "removed files", map[string]interface{}{
+			"path":  pattern,
+			"count": len(files),
+		})
+	}
+
+	return removed, nil
+}
+
+// matchesRemovePath reports whether file matches the path of a remove item:
+// the file itself, a directory containing it, or a pattern with the syntax of
+// .gitignore.
+func matchesRemovePath(pattern, file string) bool {
+	if strings.ContainsAny(pattern, "*?[") {
+		return matchesGlobs([]string{pattern}, file)
+	}
+
+	return file == pattern || strings.HasPrefix(file, pattern+"/")
+}
+
+// isExecutable reports whether the file at path in worktree is executable.
+func isExecutable(worktree *gogit.Worktree, path string) bool {
+	info, err := worktree.Filesystem.Lstat(path)
+	return err == nil && info.Mode()&0111 != 0
+}
+
+// checkExpectedSHAs checks that the files in worktree of the items with an
+// expected_sha have that blob sha, except the items already in applied, the
+// items of the last apply.
+func checkExpectedSHAs(worktree *gogit.Worktree, items, applied []interface{}) diag.Diagnostics {
+	appliedHashes := make(map[int]bool)
+	for _, item := range applied {
+		appliedHashes[hashAddItem(item)] = true
+	}
+
+	for _, item := range items {
+		expected := item.(map[string]interface{})["expected_sha"].(string)
+		if expected == "" || appliedHashes[hashAddItem(item)] {
+			continue
+		}
+
+		path := item.(map[string]interface{})["path"].(string)
+		content, err := util.ReadFile(worktree.Filesystem, worktree.Filesystem.Join(path))
+		if errors.Is(err, os.ErrNotExist) {
+			return diag.Errorf("file %s was removed from the repository, expected blob %s", path, expected)
+		} else if err != nil {
+			return diag.Errorf("failed to read file %s: %s", path, err)
+		}
+
+		if actual := plumbing.ComputeHash(plumbing.BlobObject, content); actual.String() != expected {
+			return diag.Errorf("file %s was changed in the repository: its blob is %s, expected %s", path, actual, expected)
+		}
+	}
+
+	return nil
+}
+
+// resolveConflicts returns items, applying the conflict strategy of those
+// whose file in worktree was changed since the content in applied, the items
+// of the last apply, was written: it fails, or merges the changes into the
+// content of the item.
+func resolveConflicts(worktree *gogit.Worktree, items, applied []interface{}) ([]interface{}, diag.Diagnostics) {
+	appliedContent := make(map[string][]byte)
+	for _, item := range applied {
+		content, err := addContent(item.(map[string]interface{}))
+		if err != nil {
+			return nil, diag.FromErr(err)
+		}
+		appliedContent[item.(map[string]interface{})["path"].(string)] = content
+	}
+
+	resolved := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		path := item.(map[string]interface{})["path"].(string)
+		ignoreWhitespace := item.(map[string]interface{})["ignore_whitespace"].(bool)
+		strategy := item.(map[string]interface{})["conflict_strategy"].(string)
+
+		// An unset strategy overwrites the changes, like overwrite
+		base, ok := appliedContent[path]
+		if !ok || item.(map[string]interface{})["create_only"].(bool) || item.(map[string]interface{})["merge"].(bool) || (strategy != conflictStrategyFail && strategy != conflictStrategyMerge) {
+			resolved = append(resolved, item)
+			continue
+		}
+
+		current, err := util.ReadFile(worktree.Filesystem, worktree.Filesystem.Join(path))
+		if errors.Is(err, os.ErrNotExist) {
+			current = nil
+		} else if err != nil {
+			return nil, diag.Errorf("failed to read file %s: %s", path, err)
+		}
+		if current != nil && (bytes.Equal(current, base) || ignoreWhitespace && collapseWhitespace(string(current)) == collapseWhitespace(string(base))) {
+			resolved = append(resolved, item)
+			continue
+		}
+
+		if strategy == conflictStrategyFail {
+			return nil, diag.Errorf("file %s was changed in the repository since it was last applied: merge the changes into its content, or set its conflict_strategy to overwrite or merge", path)
+		}
+
+		content, err := addContent(item.(map[string]interface{}))
+		if err != nil {
+			return nil, diag.FromErr(err)
+		}
+		merged, ok := mergeContent(base, content, current)
+		if !ok {
+			return nil, diag.Errorf("failed to merge file %s: the changes made in the repository since it was last applied conflict with the changes to its content", path)
+		}
+
+		mergedItem := make(map[string]interface{})
+		for k, v := range item.(map[string]interface{}) {
+			mergedItem[k] = v
+		}
+		mergedItem["content"] = string(merged)
+		mergedItem["content_base64"] = ""
+		resolved = append(resolved, mergedItem)
+	}
+
+	return resolved, nil
+}
+
+// convertEOL converts the line endings of text content to eol. With auto,
+// the line endings of existing, the content of the file in the repository,
+// are used. Binary content, containing a NUL byte, is returned as is.
+func convertEOL(content []byte, eol string, existing []byte) []byte {
+	if eol == "" || bytes.IndexByte(content, 0) >= 0 {
+		return content
+	}
+	if eol == eolAuto {
+		eol = eolLF
+		if bytes.Contains(existing, []byte("\r\n")) {
+			eol = eolCRLF
+		}
+	}
+
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	if eol == eolCRLF {
+		content = bytes.ReplaceAll(content, []byte("\n"), []byte("\r\n"))
+	}
+
+	return content
+}
+
+// ensureTrailingNewline returns content ending with a newline, unless it is
+// empty.
+func ensureTrailingNewline(content []byte) []byte {
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		return append(content, '\n')
+	}
+
+	return content
+}
+
+// collapseWhitespace replaces every run of whitespace, including blank lines,
+// with a single space and trims leading and trailing whitespace.
+func collapseWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
 }
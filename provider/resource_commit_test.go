@@ -0,0 +1,1030 @@
+package provider
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"golang.org/x/crypto/ssh"
+)
+
+// newTestRemote returns the URL of a repository served over HTTP, with a
+// first commit adding README on main.
+func newTestRemote(t *testing.T) string {
+	t.Helper()
+
+	url, _ := newTestRemoteDir(t)
+	return url
+}
+
+// newTestRemoteDir is newTestRemote, also returning the path of the bare
+// repository served.
+func newTestRemoteDir(t *testing.T) (string, string) {
+	t.Helper()
+
+	root := t.TempDir()
+	s, err := startGitServer("127.0.0.1:0", root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	url := s.URL() + "/test.git"
+
+	repo, err := gogit.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeTestFile(worktree, "README", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worktree.Commit("Initial commit", &gogit.CommitOptions{Author: testSignature()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{url}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Push(&gogit.PushOptions{RefSpecs: []config.RefSpec{"refs/heads/master:refs/heads/main"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	return url, filepath.Join(root, "test.git")
+}
+
+// writeTestFile writes and stages the file at path of worktree.
+func writeTestFile(worktree *gogit.Worktree, path, content string) error {
+	f, err := worktree.Filesystem.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write([]byte(content)); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	_, err = worktree.Add(path)
+	return err
+}
+
+func testSignature() *object.Signature {
+	return &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+}
+
+// testCommitData returns the data of a git_commit resource committing to
+// main of url as the test author.
+func testCommitData(t *testing.T, url string, raw map[string]interface{}) *schema.ResourceData {
+	t.Helper()
+
+	raw["url"] = url
+	if _, ok := raw["branch"]; !ok {
+		raw["branch"] = "main"
+	}
+	raw["author"] = []interface{}{map[string]interface{}{"name": "test", "email": "test@example.com"}}
+	return schema.TestResourceDataRaw(t, resourceCommit().Schema, raw)
+}
+
+// applyTestCommit plans and applies a git_commit resource with raw as its
+// configuration on top of state, which is nil to create it, committing to
+// main of url as the test author. It returns the new state.
+func applyTestCommit(t *testing.T, state *terraform.InstanceState, url string, raw map[string]interface{}) *terraform.InstanceState {
+	t.Helper()
+
+	state, diags := applyTestCommitConfig(t, &providerConfig{}, state, url, raw)
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	return state
+}
+
+// applyTestCommitConfig is applyTestCommit with the provider configuration
+// cfg, returning the diagnostics of the apply.
+func applyTestCommitConfig(t *testing.T, cfg *providerConfig, state *terraform.InstanceState, url string, raw map[string]interface{}) (*terraform.InstanceState, diag.Diagnostics) {
+	t.Helper()
+
+	raw["url"] = url
+	if _, ok := raw["branch"]; !ok {
+		raw["branch"] = "main"
+	}
+	raw["author"] = []interface{}{map[string]interface{}{"name": "test", "email": "test@example.com"}}
+
+	ctx := context.Background()
+	resource := resourceCommit()
+	diff, err := resource.Diff(ctx, state, terraform.NewResourceConfigRaw(raw), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resource.Apply(ctx, state, diff, cfg)
+}
+
+// remoteCommit returns the commit ref points to in the repository at url.
+func remoteCommit(t *testing.T, url, ref string) *object.Commit {
+	t.Helper()
+
+	repo, err := gogit.Clone(memory.NewStorage(), nil, &gogit.CloneOptions{URL: url, ReferenceName: plumbing.ReferenceName(ref)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return commit
+}
+
+func TestResourceCommitStateWithAddList(t *testing.T) {
+	// add was a list before it became a set; both are arrays in the state
+	state := `{
+		"id": "main",
+		"url": "https://example.com/repo.git",
+		"branch": "main",
+		"message": "Committed with Terraform",
+		"add": [{"path": "README.md", "content": "hi"}],
+		"remove": [],
+		"prune": false,
+		"sha": "0123456789012345678901234567890123456789",
+		"new": true
+	}`
+
+	server := schema.NewGRPCProviderServer(Provider())
+	resp, err := server.UpgradeResourceState(context.Background(), &tfprotov5.UpgradeResourceStateRequest{
+		TypeName: "git_commit",
+		Version:  0,
+		RawState: &tfprotov5.RawState{JSON: []byte(state)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range resp.Diagnostics {
+		t.Errorf("%s: %s", d.Summary, d.Detail)
+	}
+}
+
+func TestResourceCommitSSHSigningKeyFile(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	url := newTestRemote(t)
+	d := testCommitData(t, url, map[string]interface{}{
+		"add":                  []interface{}{map[string]interface{}{"path": "signed", "content": "yes"}},
+		"ssh_signing_key_file": path,
+	})
+	if diags := resourceCommitCreate(context.Background(), d, &providerConfig{}); diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	commit := remoteCommit(t, url, "refs/heads/main")
+	if !strings.HasPrefix(commit.PGPSignature, "-----BEGIN SSH SIGNATURE-----") {
+		t.Errorf("signature = %q, want an SSH signature", commit.PGPSignature)
+	}
+}
+
+func TestResourceCommitAmendRootCommit(t *testing.T) {
+	url := newTestRemote(t)
+	raw := func(content string) map[string]interface{} {
+		return map[string]interface{}{
+			"branch":          "amend",
+			"orphan":          true,
+			"update_strategy": updateStrategyAmend,
+			"add":             []interface{}{map[string]interface{}{"path": "file", "content": content}},
+		}
+	}
+
+	state := applyTestCommit(t, nil, url, raw("first"))
+	root := remoteCommit(t, url, "refs/heads/amend")
+	if root.NumParents() != 0 {
+		t.Fatalf("commit of a new branch has %d parents, want a root commit", root.NumParents())
+	}
+
+	// A root commit has no parent to amend it onto, so a commit is added
+	state = applyTestCommit(t, state, url, raw("second"))
+	commit := remoteCommit(t, url, "refs/heads/amend")
+	if commit.NumParents() != 1 || commit.ParentHashes[0] != root.Hash {
+		t.Errorf("parents = %v, want the root commit %s", commit.ParentHashes, root.Hash)
+	}
+
+	// The commit on top of the root commit is amended
+	applyTestCommit(t, state, url, raw("third"))
+	amended := remoteCommit(t, url, "refs/heads/amend")
+	if amended.NumParents() != 1 || amended.ParentHashes[0] != root.Hash {
+		t.Errorf("parents = %v, want the root commit %s", amended.ParentHashes, root.Hash)
+	}
+}
+
+func TestPushBranchLease(t *testing.T) {
+	url := newTestRemote(t)
+	base := remoteCommit(t, url, "refs/heads/main").Hash
+
+	clone := func() (*gogit.Repository, *gogit.Worktree) {
+		t.Helper()
+		repo, err := gogit.Clone(memory.NewStorage(), memfs.New(), &gogit.CloneOptions{URL: url})
+		if err != nil {
+			t.Fatal(err)
+		}
+		worktree, err := repo.Worktree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repo, worktree
+	}
+	// amend replaces the head of main with a commit without parent
+	amend := func(repo *gogit.Repository, worktree *gogit.Worktree, content string) {
+		t.Helper()
+		if err := writeTestFile(worktree, "README", content); err != nil {
+			t.Fatal(err)
+		}
+		sha, err := worktree.Commit("Amended", &gogit.CommitOptions{Author: testSignature(), Parents: []plumbing.Hash{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Storer.SetReference(plumbing.NewHashReference(plumbing.Main, sha)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, firstWorktree := clone()
+	second, secondWorktree := clone()
+
+	amend(first, firstWorktree, "first")
+	if _, _, diags := pushBranch(context.Background(), &providerConfig{}, first, pushBranchOptions{remoteName: gogit.DefaultRemoteName, branchRef: plumbing.Main, remoteRef: plumbing.Main, lease: &base}); diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	// The branch was updated since the second clone read it
+	amend(second, secondWorktree, "second")
+	_, rejected, diags := pushBranch(context.Background(), &providerConfig{}, second, pushBranchOptions{remoteName: gogit.DefaultRemoteName, branchRef: plumbing.Main, remoteRef: plumbing.Main, lease: &base})
+	if !rejected || !diags.HasError() || !strings.Contains(diags[0].Detail, "force push was aborted") {
+		t.Errorf("pushBranch() = %v, %v, want a rejected push as the branch was updated", rejected, diags)
+	}
+}
+
+func TestIsRejectedPush(t *testing.T) {
+	url := newTestRemote(t)
+	base := remoteCommit(t, url, "refs/heads/main").Hash
+
+	clone := func(content string) *gogit.Repository {
+		t.Helper()
+		repo, err := gogit.Clone(memory.NewStorage(), memfs.New(), &gogit.CloneOptions{URL: url})
+		if err != nil {
+			t.Fatal(err)
+		}
+		worktree, err := repo.Worktree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writeTestFile(worktree, "README", content); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := worktree.Commit(content, &gogit.CommitOptions{Author: testSignature()}); err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	}
+	// A push refused for another reason, e.g. by a pre-receive hook
+	declined := errors.New("command error on refs/heads/main: pre-receive hook declined")
+
+	local := clone("local")
+	if isRejectedPush(context.Background(), local, gogit.DefaultRemoteName, "", plumbing.Main, plumbing.Main, nil, nil, declined) {
+		t.Error("isRejectedPush() = true for an unchanged branch")
+	}
+	if isRejectedPush(context.Background(), local, gogit.DefaultRemoteName, "", plumbing.Main, plumbing.Main, nil, &base, declined) {
+		t.Error("isRejectedPush() = true for an unchanged lease")
+	}
+
+	// The branch is updated by another clone
+	if err := clone("concurrent").Push(&gogit.PushOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !isRejectedPush(context.Background(), local, gogit.DefaultRemoteName, "", plumbing.Main, plumbing.Main, nil, nil, declined) {
+		t.Error("isRejectedPush() = false for an updated branch")
+	}
+	if !isRejectedPush(context.Background(), local, gogit.DefaultRemoteName, "", plumbing.Main, plumbing.Main, nil, &base, declined) {
+		t.Error("isRejectedPush() = false for an updated lease")
+	}
+}
+
+func TestResourceCommitConflictStrategyUnset(t *testing.T) {
+	url := newTestRemote(t)
+	raw := func() map[string]interface{} {
+		return map[string]interface{}{
+			"add": []interface{}{map[string]interface{}{"path": "file", "content": "content"}},
+		}
+	}
+	state := applyTestCommit(t, nil, url, raw())
+
+	// States written before conflict_strategy existed do not have it
+	for key := range state.Attributes {
+		if strings.HasSuffix(key, ".conflict_strategy") {
+			delete(state.Attributes, key)
+		}
+	}
+
+	config := raw()
+	config["url"], config["branch"] = url, "main"
+	config["author"] = []interface{}{map[string]interface{}{"name": "test", "email": "test@example.com"}}
+	diff, err := resourceCommit().Diff(context.Background(), state, terraform.NewResourceConfigRaw(config), &providerConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Errorf("diff = %v, want no changes", diff)
+	}
+}
+
+func TestResourceCommitTagsUnchangedFiles(t *testing.T) {
+	url := newTestRemote(t)
+	head := remoteCommit(t, url, "refs/heads/main").Hash
+
+	// README already has this content, so no commit is needed
+	state := applyTestCommit(t, nil, url, map[string]interface{}{
+		"add":  []interface{}{map[string]interface{}{"path": "README", "content": "hi"}},
+		"tags": []interface{}{map[string]interface{}{"name": "v1"}},
+	})
+	if state.Attributes["sha"] != head.String() || state.Attributes["new"] != "false" {
+		t.Fatalf("sha = %s, new = %s, want the existing commit %s", state.Attributes["sha"], state.Attributes["new"], head)
+	}
+	if tag := remoteCommit(t, url, "refs/tags/v1").Hash; tag != head {
+		t.Errorf("tag v1 points to %s, want the existing commit %s", tag, head)
+	}
+
+	applyTestCommit(t, state, url, map[string]interface{}{
+		"add":  []interface{}{map[string]interface{}{"path": "README", "content": "hi"}},
+		"tags": []interface{}{map[string]interface{}{"name": "v1"}, map[string]interface{}{"name": "v2"}},
+	})
+	if tag := remoteCommit(t, url, "refs/tags/v2").Hash; tag != head {
+		t.Errorf("tag v2 points to %s, want the existing commit %s", tag, head)
+	}
+}
+
+func TestResourceCommitRemoteName(t *testing.T) {
+	url := newTestRemote(t)
+	head := remoteCommit(t, url, "refs/heads/main").Hash
+
+	state := applyTestCommit(t, nil, url, map[string]interface{}{
+		"remote_name": "upstream",
+		"push_url":    url,
+		"add":         []interface{}{map[string]interface{}{"path": "file", "content": "one"}},
+	})
+	commit := remoteCommit(t, url, "refs/heads/main")
+	if state.Attributes["sha"] != commit.Hash.String() || len(commit.ParentHashes) != 1 || commit.ParentHashes[0] != head {
+		t.Fatalf("sha = %s, want a commit on top of %s pushed to main", state.Attributes["sha"], head)
+	}
+
+	state = applyTestCommit(t, state, url, map[string]interface{}{
+		"remote_name": "upstream",
+		"push_url":    url,
+		"add":         []interface{}{map[string]interface{}{"path": "file", "content": "two"}},
+	})
+	updated := remoteCommit(t, url, "refs/heads/main")
+	if state.Attributes["sha"] != updated.Hash.String() || len(updated.ParentHashes) != 1 || updated.ParentHashes[0] != commit.Hash {
+		t.Errorf("sha = %s, want a commit on top of %s pushed to main", state.Attributes["sha"], commit.Hash)
+	}
+}
+
+func TestValidateAddSources(t *testing.T) {
+	item := func(sources map[string]cty.Value) cty.Value {
+		attrs := map[string]cty.Value{"path": cty.StringVal("file")}
+		for _, key := range addSources {
+			attrs[key] = cty.NullVal(cty.String)
+			if value, ok := sources[key]; ok {
+				attrs[key] = value
+			}
+		}
+		return cty.ObjectVal(attrs)
+	}
+
+	tests := map[string]struct {
+		sources map[string]cty.Value
+		wantErr string
+	}{
+		"content": {
+			sources: map[string]cty.Value{"content": cty.StringVal("hi")},
+		},
+		"empty content": {
+			sources: map[string]cty.Value{"content": cty.StringVal("")},
+		},
+		"source file": {
+			sources: map[string]cty.Value{"source_file": cty.StringVal("file.txt")},
+		},
+		"none": {
+			wantErr: "one of content, content_base64 and source_file must be set for add path file",
+		},
+		"two": {
+			sources: map[string]cty.Value{"content": cty.StringVal(""), "content_base64": cty.StringVal("aGk=")},
+			wantErr: "only one of content, content_base64 and source_file can be set for add path file",
+		},
+		"unknown": {
+			sources: map[string]cty.Value{"content": cty.UnknownVal(cty.String)},
+		},
+		"unknown and known": {
+			sources: map[string]cty.Value{"content": cty.UnknownVal(cty.String), "source_file": cty.StringVal("file.txt")},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			raw := cty.ObjectVal(map[string]cty.Value{"add": cty.SetVal([]cty.Value{item(tt.sources)})})
+			err := validateAddSources(raw)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("validateAddSources() = %s, want no error", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("validateAddSources() = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResourceCommitCreatePrunesSourceDir(t *testing.T) {
+	url := newTestRemote(t)
+	applyTestCommit(t, nil, url, map[string]interface{}{
+		"add": []interface{}{map[string]interface{}{"path": "chart/stale.yaml", "content": "stale"}},
+	})
+
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "values.yaml"), []byte("replicas: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	applyTestCommit(t, nil, url, map[string]interface{}{
+		"source_dir": []interface{}{map[string]interface{}{"source": source, "path": "chart"}},
+		"prune":      true,
+	})
+
+	tree, err := remoteCommit(t, url, "refs/heads/main").Tree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.File("chart/values.yaml"); err != nil {
+		t.Errorf("chart/values.yaml: %s, want it added from the source directory", err)
+	}
+	if _, err := tree.File("chart/stale.yaml"); !errors.Is(err, object.ErrFileNotFound) {
+		t.Errorf("chart/stale.yaml: %v, want it pruned", err)
+	}
+	if _, err := tree.File("README"); err != nil {
+		t.Errorf("README: %s, want it kept outside of the source directory", err)
+	}
+}
+
+func TestSourceDirItemsDefaults(t *testing.T) {
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "run.sh"), []byte("#!/bin/sh\n"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := sourceDirItems([]interface{}{map[string]interface{}{
+		"source":  source,
+		"path":    "bin",
+		"include": []interface{}{},
+		"exclude": []interface{}{},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("sourceDirItems() returned %d items, want 1", len(items))
+	}
+	item := items[0].(map[string]interface{})
+	for key := range resourceCommitAdd().Schema {
+		if _, ok := item[key]; !ok {
+			t.Errorf("item has no %s, want every attribute of add", key)
+		}
+	}
+	if item["path"] != "bin/run.sh" || item["source_file"] != filepath.Join(source, "run.sh") || item["executable"] != true {
+		t.Errorf("item = %v, want bin/run.sh read from its source file and executable", item)
+	}
+}
+
+func TestResourceCommitConflictStrategyAppend(t *testing.T) {
+	for _, mode := range []string{"append", "merge"} {
+		for _, strategy := range []string{conflictStrategyFail, conflictStrategyMerge} {
+			config := map[string]interface{}{
+				"url":    "https://example.com/repo.git",
+				"branch": "main",
+				"add": []interface{}{map[string]interface{}{
+					"path":              "file",
+					"content":           "line\n",
+					mode:                true,
+					"conflict_strategy": strategy,
+				}},
+			}
+			_, err := resourceCommit().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), &providerConfig{})
+			if err == nil || !strings.Contains(err.Error(), "cannot be set with append or merge") {
+				t.Errorf("Diff() with %s and conflict_strategy %s = %v, want an error", mode, strategy, err)
+			}
+		}
+	}
+}
+
+func TestPruneFileKeepsMode(t *testing.T) {
+	repo, err := gogit.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(worktree.Filesystem, "run.sh", []byte("#!/bin/sh\necho hi\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	item := addItemDefaults()
+	item["path"], item["content"], item["append"] = "run.sh", "echo hi\n", true
+	if err := pruneFile(worktree, item); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := util.ReadFile(worktree.Filesystem, "run.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "#!/bin/sh\n" {
+		t.Errorf("content = %q, want the appended content removed", content)
+	}
+	info, err := worktree.Filesystem.Lstat("run.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o755 {
+		t.Errorf("mode = %s, want the executable mode kept", info.Mode())
+	}
+}
+
+func TestResourceCommitFormatValidation(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		wantErr bool
+	}{
+		"valid":   {content: `{"replicas": 1}`},
+		"invalid": {content: `{"replicas": 1`, wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := map[string]interface{}{
+				"url":    "https://example.com/repo.git",
+				"branch": "main",
+				"add":    []interface{}{map[string]interface{}{"path": "values.json", "content": tt.content, "format": formatJSON}},
+			}
+			_, err := resourceCommit().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(config), &providerConfig{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Diff() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckExpectedParent(t *testing.T) {
+	url := newTestRemote(t)
+	head := remoteCommit(t, url, "refs/heads/main").Hash
+
+	ctx := context.Background()
+	cfg := &providerConfig{}
+	repo, err := cfg.clone(ctx, url, gogit.DefaultRemoteName, memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diags := checkExpectedParent(ctx, cfg, repo, gogit.DefaultRemoteName, "", plumbing.Main, nil, head); diags.HasError() {
+		t.Errorf("checkExpectedParent() = %v, want no error for the tip of main", diags)
+	}
+
+	other := plumbing.NewHash(strings.Repeat("1", 40))
+	diags := checkExpectedParent(ctx, cfg, repo, gogit.DefaultRemoteName, "", plumbing.Main, nil, other)
+	if want := "points to " + head.String() + ", expected " + other.String(); !diags.HasError() || !strings.Contains(diags[0].Summary, want) || !strings.Contains(diags[0].Detail, "expected_parent_sha") {
+		t.Errorf("checkExpectedParent() = %v, want %s", diags, want)
+	}
+
+	diags = checkExpectedParent(ctx, cfg, repo, gogit.DefaultRemoteName, "", plumbing.NewBranchReferenceName("missing"), nil, head)
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "does not exist") {
+		t.Errorf("checkExpectedParent() = %v, want an error for a missing branch", diags)
+	}
+}
+
+func TestResourceCommitExpectedParent(t *testing.T) {
+	url := newTestRemote(t)
+	head := remoteCommit(t, url, "refs/heads/main").Hash
+
+	state := applyTestCommit(t, nil, url, map[string]interface{}{
+		"add":                 []interface{}{map[string]interface{}{"path": "file", "content": "one"}},
+		"expected_parent_sha": head.String(),
+	})
+	commit := remoteCommit(t, url, "refs/heads/main")
+	if state.Attributes["sha"] != commit.Hash.String() || len(commit.ParentHashes) != 1 || commit.ParentHashes[0] != head {
+		t.Errorf("sha = %s, want a commit on top of the expected parent %s", state.Attributes["sha"], head)
+	}
+}
+
+func TestResourceCommitUpdateOrphanBranch(t *testing.T) {
+	url, dir := newTestRemoteDir(t)
+	raw := func(content string) map[string]interface{} {
+		return map[string]interface{}{
+			"branch": "feature",
+			"orphan": true,
+			"add":    []interface{}{map[string]interface{}{"path": "file", "content": content}},
+		}
+	}
+	state := applyTestCommit(t, nil, url, raw("first"))
+
+	// The branch is deleted upstream, so the update starts an orphan branch
+	repo, err := gogit.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Storer.RemoveReference(plumbing.NewBranchReferenceName("feature")); err != nil {
+		t.Fatal(err)
+	}
+
+	state = applyTestCommit(t, state, url, raw("second"))
+	commit := remoteCommit(t, url, "refs/heads/feature")
+	if state.ID != commit.Hash.String() || state.Attributes["sha"] != commit.Hash.String() {
+		t.Errorf("id = %s, sha = %s, want the pushed commit %s", state.ID, state.Attributes["sha"], commit.Hash)
+	}
+	if commit.NumParents() != 0 {
+		t.Errorf("commit has %d parents, want a root commit", commit.NumParents())
+	}
+}
+
+func TestResourceCommitRemoteNameBaseRef(t *testing.T) {
+	url := newTestRemote(t)
+	applyTestCommit(t, nil, url, map[string]interface{}{
+		"branch":        "other",
+		"create_branch": true,
+		"add":           []interface{}{map[string]interface{}{"path": "file", "content": "other"}},
+	})
+	other := remoteCommit(t, url, "refs/heads/other").Hash
+
+	// base_ref is resolved against the remote-tracking branches of remote_name
+	applyTestCommit(t, nil, url, map[string]interface{}{
+		"remote_name":   "upstream",
+		"branch":        "feature",
+		"create_branch": true,
+		"base_ref":      "other",
+		"add":           []interface{}{map[string]interface{}{"path": "feature", "content": "feature"}},
+	})
+	commit := remoteCommit(t, url, "refs/heads/feature")
+	if commit.NumParents() != 1 || commit.ParentHashes[0] != other {
+		t.Errorf("parents = %v, want the tip of other %s", commit.ParentHashes, other)
+	}
+}
+
+func TestCommitOptionsReproducibleIdentity(t *testing.T) {
+	repo, err := gogit.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := &object.Signature{Name: "runner", Email: "runner@example.com"}
+	configured := &object.Signature{Name: "configured", Email: "configured@example.com"}
+	raw := map[string]interface{}{"reproducible": true, "timestamp": "2024-01-02T03:04:05Z"}
+
+	tests := []struct {
+		name string
+		cfg  *providerConfig
+		want string
+	}{
+		{
+			name: "environment",
+			cfg:  &providerConfig{author: runner, committer: runner, envAuthor: true, envCommitter: true},
+			want: reproducibleSignature.Name,
+		},
+		{
+			name: "provider configuration",
+			cfg:  &providerConfig{author: configured, committer: configured},
+			want: configured.Name,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := commitOptions(schema.TestResourceDataRaw(t, resourceCommit().Schema, raw), tt.cfg, repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := opts.Validate(repo); err != nil {
+				t.Fatal(err)
+			}
+			if opts.Author.Name != tt.want || opts.Committer.Name != tt.want {
+				t.Errorf("author = %s, committer = %s, want %s", opts.Author.Name, opts.Committer.Name, tt.want)
+			}
+			if want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC); !opts.Author.When.Equal(want) || !opts.Committer.When.Equal(want) {
+				t.Errorf("author date = %s, committer date = %s, want %s", opts.Author.When, opts.Committer.When, want)
+			}
+		})
+	}
+}
+
+func TestLineBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		block   string
+		found   bool
+		pruned  string
+	}{
+		{name: "whole line", content: "a\nfoo\nb\n", block: "foo", found: true, pruned: "a\nb\n"},
+		{name: "line with its newline", content: "a\nfoo\nb\n", block: "foo\n", found: true, pruned: "a\nb\n"},
+		{name: "substring of a line", content: "foobar\n", block: "foo"},
+		{name: "end of a line", content: "barfoo\n", block: "foo"},
+		{name: "later whole line", content: "foobar\nfoo\n", block: "foo", found: true, pruned: "foobar\n"},
+		{name: "appended separator", content: "a\nfoo", block: "foo", found: true, pruned: "a"},
+		{name: "crlf", content: "a\r\nfoo\r\nb\r\n", block: "foo", found: true, pruned: "a\r\nb\r\n"},
+		{name: "crlf appended separator", content: "a\r\nfoo", block: "foo", found: true, pruned: "a"},
+		{name: "block of lines", content: "a\nfoo\nbar\nb\n", block: "foo\nbar\n", found: true, pruned: "a\nb\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if found := lineBlockIndex([]byte(tt.content), []byte(tt.block)) >= 0; found != tt.found {
+				t.Errorf("lineBlockIndex() found = %v, want %v", found, tt.found)
+			}
+			pruned, ok := removeLineBlock([]byte(tt.content), []byte(tt.block))
+			if ok != tt.found || (ok && string(pruned) != tt.pruned) {
+				t.Errorf("removeLineBlock() = %q, %v, want %q, %v", pruned, ok, tt.pruned, tt.found)
+			}
+		})
+	}
+}
+
+func TestAppendContentSubstring(t *testing.T) {
+	repo, err := gogit.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(worktree.Filesystem, "hosts", []byte("foobar"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	item := addItemDefaults()
+	item["path"], item["content"], item["append"] = "hosts", "foo", true
+	appended, ok, err := appendContent(worktree, "hosts", item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || string(appended) != "foobar\nfoo" {
+		t.Fatalf("appendContent() = %q, %v, want foo appended on a new line", appended, ok)
+	}
+
+	if err := util.WriteFile(worktree.Filesystem, "hosts", appended, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := pruneFile(worktree, item); err != nil {
+		t.Fatal(err)
+	}
+	content, err := util.ReadFile(worktree.Filesystem, "hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "foobar" {
+		t.Errorf("content = %q, want the file as it was before the append", content)
+	}
+}
+
+func TestResourceCommitRemovedCount(t *testing.T) {
+	url := newTestRemote(t)
+	applyTestCommit(t, nil, url, map[string]interface{}{
+		"add": []interface{}{
+			map[string]interface{}{"path": "configs/a/old-1.yaml", "content": "1"},
+			map[string]interface{}{"path": "configs/b/old-2.yaml", "content": "2"},
+		},
+	})
+
+	state := applyTestCommit(t, nil, url, map[string]interface{}{
+		"remove": []interface{}{
+			map[string]interface{}{"path": "configs/**/old-*.yaml"},
+			map[string]interface{}{"path": "missing"},
+		},
+	})
+	if state.Attributes["removed_count"] != "2" {
+		t.Errorf("removed_count = %s, want 2", state.Attributes["removed_count"])
+	}
+}
+
+func TestResourceCommitSplitCommits(t *testing.T) {
+	url := newTestRemote(t)
+	head := remoteCommit(t, url, "refs/heads/main").Hash
+	raw := func(branch string) map[string]interface{} {
+		return map[string]interface{}{
+			"branch":        branch,
+			"create_branch": true,
+			"message":       "Update",
+			"split_commits": true,
+			"reproducible":  true,
+			"timestamp":     "2024-01-02T03:04:05Z",
+			"add": []interface{}{
+				map[string]interface{}{"path": "b.txt", "content": "b"},
+				map[string]interface{}{"path": "a.txt", "content": "a"},
+			},
+			"remove": []interface{}{map[string]interface{}{"path": "README"}},
+		}
+	}
+
+	// The same inputs on top of the same commit yield the same commits
+	first := applyTestCommit(t, nil, url, raw("first"))
+	second := applyTestCommit(t, nil, url, raw("second"))
+	if first.Attributes["sha"] != second.Attributes["sha"] {
+		t.Errorf("sha = %s and %s, want the same commit for the same inputs", first.Attributes["sha"], second.Attributes["sha"])
+	}
+
+	// A commit per block, the add blocks in the order of their paths
+	want := []string{"Update: remove README", "Update: add b.txt", "Update: add a.txt"}
+	commit := remoteCommit(t, url, "refs/heads/first")
+	when := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, message := range want {
+		if got := strings.TrimSpace(commit.Message); got != message {
+			t.Errorf("message = %q, want %q", got, message)
+		}
+		if commit.Author.Name != "test" || !commit.Author.When.Equal(when) || !commit.Committer.When.Equal(when) {
+			t.Errorf("author = %v, committer = %v, want the test author at %s", commit.Author, commit.Committer, when)
+		}
+		if commit.NumParents() != 1 {
+			t.Fatalf("commit %s has %d parents, want 1", commit.Hash, commit.NumParents())
+		}
+		parent, err := commit.Parent(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		commit = parent
+	}
+	if commit.Hash != head {
+		t.Errorf("commits are on top of %s, want the tip of main %s", commit.Hash, head)
+	}
+}
+
+// advancingRoundTripper adds a commit to branch of the bare repository at dir
+// before each of the first advances pushes, like a concurrent pipeline
+// pushing between the clone and the push of an apply.
+type advancingRoundTripper struct {
+	t        *testing.T
+	dir      string
+	branch   plumbing.ReferenceName
+	advances int
+	pushes   int
+}
+
+func (a *advancingRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Method == http.MethodGet && r.URL.Query().Get("service") == "git-receive-pack" {
+		a.pushes++
+		if a.pushes <= a.advances {
+			if err := a.advance(); err != nil {
+				a.t.Errorf("failed to advance %s: %s", a.branch, err)
+			}
+		}
+	}
+
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func (a *advancingRoundTripper) advance() error {
+	repo, err := gogit.PlainOpen(a.dir)
+	if err != nil {
+		return err
+	}
+	ref, err := repo.Reference(a.branch, true)
+	if err != nil {
+		return err
+	}
+	parent, err := repo.CommitObject(ref.Hash())
+	if err != nil {
+		return err
+	}
+
+	commit := &object.Commit{
+		Author:       *testSignature(),
+		Committer:    *testSignature(),
+		Message:      fmt.Sprintf("Concurrent commit %d", a.pushes),
+		TreeHash:     parent.TreeHash,
+		ParentHashes: []plumbing.Hash{parent.Hash},
+	}
+	obj := repo.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return err
+	}
+	sha, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return err
+	}
+
+	return repo.Storer.SetReference(plumbing.NewHashReference(a.branch, sha))
+}
+
+func TestResourceCommitPushRetries(t *testing.T) {
+	installGitTransport()
+	raw := func(retries int) map[string]interface{} {
+		return map[string]interface{}{
+			"push_retries": retries,
+			"add":          []interface{}{map[string]interface{}{"path": "file", "content": "content"}},
+		}
+	}
+
+	t.Run("rebuilt on the new tip", func(t *testing.T) {
+		url, dir := newTestRemoteDir(t)
+		advancing := &advancingRoundTripper{t: t, dir: dir, branch: plumbing.Main, advances: 2}
+		state, diags := applyTestCommitConfig(t, &providerConfig{gitTransport: advancing}, nil, url, raw(2))
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+		if advancing.pushes != 3 {
+			t.Errorf("pushed %d times, want 3", advancing.pushes)
+		}
+
+		commit := remoteCommit(t, url, "refs/heads/main")
+		if state.Attributes["sha"] != commit.Hash.String() {
+			t.Errorf("sha = %s, want the tip of main %s", state.Attributes["sha"], commit.Hash)
+		}
+		parent, err := commit.Parent(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parent.Message != "Concurrent commit 2" {
+			t.Errorf("parent = %q, want the last concurrent commit", parent.Message)
+		}
+	})
+
+	t.Run("stops at the limit", func(t *testing.T) {
+		url, dir := newTestRemoteDir(t)
+		advancing := &advancingRoundTripper{t: t, dir: dir, branch: plumbing.Main, advances: 10}
+		if _, diags := applyTestCommitConfig(t, &providerConfig{gitTransport: advancing}, nil, url, raw(1)); !diags.HasError() {
+			t.Error("apply succeeded, want the rejected push to fail once the retries are exhausted")
+		}
+		if advancing.pushes != 2 {
+			t.Errorf("pushed %d times, want 2", advancing.pushes)
+		}
+	})
+}
+
+func TestResourceCommitMoveOntoExistingPath(t *testing.T) {
+	url := newTestRemote(t)
+	applyTestCommit(t, nil, url, map[string]interface{}{
+		"add": []interface{}{
+			map[string]interface{}{"path": "old", "content": "moved"},
+			map[string]interface{}{"path": "new", "content": "existing"},
+			map[string]interface{}{"path": "dir/file", "content": "moved"},
+			map[string]interface{}{"path": "target/stale", "content": "stale"},
+		},
+	})
+
+	// Like git mv -f, the file and directory at the destination are replaced
+	applyTestCommit(t, nil, url, map[string]interface{}{
+		"move": []interface{}{
+			map[string]interface{}{"from": "old", "to": "new"},
+			map[string]interface{}{"from": "dir", "to": "target"},
+		},
+	})
+	tree, err := remoteCommit(t, url, "refs/heads/main").Tree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"new", "target/file"} {
+		file, err := tree.File(path)
+		if err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+		if content, err := file.Contents(); err != nil || content != "moved" {
+			t.Errorf("%s = %q, %v, want the moved content", path, content, err)
+		}
+	}
+	for _, path := range []string{"old", "dir/file", "target/stale"} {
+		if _, err := tree.File(path); !errors.Is(err, object.ErrFileNotFound) {
+			t.Errorf("%s: err = %v, want the file removed", path, err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// secretFileSuffix is appended to the name of a credential argument to get
+// the argument reading the credential from a file instead.
+const secretFileSuffix = "_file"
+
+// addSecretFiles adds a _file variant of each of the credential arguments
+// keys to s, so credentials can be mounted as files rather than passed
+// through variables. block is the path of the block s is the schema of, e.g.
+// `oauth2.0.`, or empty for the arguments of the provider.
+func addSecretFiles(s map[string]*schema.Schema, block string, keys ...string) {
+	for _, key := range keys {
+		s[key+secretFileSuffix] = secretFileSchema(key)
+		s[key+secretFileSuffix].ConflictsWith = []string{block + key}
+	}
+}
+
+// addRepeatedSecretFiles is addSecretFiles for blocks that can be repeated,
+// whose paths are not known. Setting both variants fails when they are read
+// instead.
+func addRepeatedSecretFiles(s map[string]*schema.Schema, keys ...string) {
+	for _, key := range keys {
+		s[key+secretFileSuffix] = secretFileSchema(key)
+	}
+}
+
+// secretFileSchema returns the schema of the _file variant of the credential
+// argument key.
+func secretFileSchema(key string) *schema.Schema {
+	return &schema.Schema{
+		Description: fmt.Sprintf("The path of a file containing `%s`, e.g. a mounted secret. Conflicts with `%s`.", key, key),
+		Type:        schema.TypeString,
+		Optional:    true,
+	}
+}
+
+// secretValue returns the credential argument key of the provider, reading it
+// from the file of its _file variant when that is set.
+func secretValue(d *schema.ResourceData, key string) (string, error) {
+	return readSecret(key, d.Get(key).(string), d.Get(key+secretFileSuffix).(string))
+}
+
+// itemSecretValue returns the credential argument key of a block, reading it
+// from the file of its _file variant when that is set.
+func itemSecretValue(item map[string]interface{}, key string) (string, error) {
+	return readSecret(key, item[key].(string), item[key+secretFileSuffix].(string))
+}
+
+// secretFileAuth returns the HTTP auth method sending username and password
+// with the auth scheme: as basic auth, or the password alone as a bearer token
+// or GitHub style token. When the password was read from the file at path,
+// the _file variant of the argument key, the file is read again before every
+// request, so rotated credentials, e.g. renewed by a Vault agent during a long
+// apply, are used as soon as they are written.
+func secretFileAuth(ctx context.Context, scheme, username, password, key, path string) (transport.AuthMethod, error) {
+	header := ""
+	switch scheme {
+	case authSchemeBearer:
+		header = "Bearer"
+	case authSchemeToken:
+		header = "token"
+	}
+
+	if path == "" {
+		if header != "" {
+			return &headerAuth{scheme: header, token: password}, nil
+		}
+		return &http.BasicAuth{
+			Username: username,
+			Password: password,
+		}, nil
+	}
+
+	if header != "" {
+		username = ""
+	}
+	auth, err := newTokenAuth(ctx, username, func(context.Context) (string, time.Time, error) {
+		password, err := readSecret(key, "", path)
+		// Expiring immediately makes the file be read for every request
+		return password, time.Now(), err
+	})
+	if err != nil {
+		return nil, err
+	}
+	auth.scheme = header
+
+	return auth, nil
+}
+
+func readSecret(key, value, path string) (string, error) {
+	if path == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("only one of %s and %s%s can be set", key, key, secretFileSuffix)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s%s: %w", key, secretFileSuffix, err)
+	}
+
+	// Files usually end with a newline that is not part of the secret
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestSecretFileConflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "value",
+			raw:  map[string]interface{}{"token": "secret"},
+		},
+		{
+			name: "file",
+			raw:  map[string]interface{}{"token_file": "/run/secrets/token"},
+		},
+		{
+			name:    "both",
+			raw:     map[string]interface{}{"token": "secret", "token_file": "/run/secrets/token"},
+			wantErr: true,
+		},
+		{
+			name: "both in a block",
+			raw: map[string]interface{}{
+				"oauth2": []interface{}{map[string]interface{}{
+					"token_url":          "https://example.com/token",
+					"client_id":          "id",
+					"client_secret":      "secret",
+					"client_secret_file": "/run/secrets/client_secret",
+				}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "both in the auth block",
+			raw: map[string]interface{}{
+				"auth": []interface{}{map[string]interface{}{
+					"token": []interface{}{map[string]interface{}{
+						"value":      "secret",
+						"value_file": "/run/secrets/token",
+					}},
+				}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diags := Provider().Validate(terraform.NewResourceConfigRaw(tt.raw))
+			if diags.HasError() != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", diags, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSecretFileToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	raw := map[string]interface{}{"gitlab_token_file": path}
+	cfg, diags := newProviderConfig(context.Background(), schema.TestResourceDataRaw(t, Provider().Schema, raw))
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+
+	auth, ok := cfg.auth.(githttp.AuthMethod)
+	if !ok {
+		t.Fatalf("auth = %T, want an HTTP auth method", cfg.auth)
+	}
+
+	if err := os.WriteFile(path, []byte("second\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r, _ := http.NewRequest(http.MethodGet, "https://gitlab.com/org/repo.git/info/refs", nil)
+	auth.SetAuth(r)
+	if username, password, _ := r.BasicAuth(); username != "oauth2" || password != "second" {
+		t.Errorf("basic auth = %s:%s, want the rotated token", username, password)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	r, _ = http.NewRequest(http.MethodGet, "https://gitlab.com/org/repo.git/info/refs", nil)
+	auth.SetAuth(r)
+	if err := authError(r); err == nil || !strings.Contains(err.Error(), "gitlab_token_file") {
+		t.Errorf("authError() = %v, want an error reading gitlab_token_file", err)
+	}
+}
+
+func TestSecretFileTokenEnvironment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("same"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// The token of the environment takes precedence, even when it matches
+	// the content of the file
+	t.Setenv("GITLAB_TOKEN", "same")
+
+	raw := map[string]interface{}{"gitlab_token_file": path}
+	cfg, diags := newProviderConfig(context.Background(), schema.TestResourceDataRaw(t, Provider().Schema, raw))
+	if diags.HasError() {
+		t.Fatal(diags)
+	}
+	if _, ok := cfg.auth.(*githttp.BasicAuth); !ok {
+		t.Errorf("auth = %T, want static basic auth", cfg.auth)
+	}
+}
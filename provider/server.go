@@ -0,0 +1,260 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/pktline"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+)
+
+const (
+	uploadPackService  = "git-upload-pack"
+	receivePackService = "git-receive-pack"
+)
+
+// gitServer is a minimal in-process smart HTTP git server. Every path below
+// the server's URL maps to a bare repository in the root directory, which is
+// initialized on first use with a default branch of main. It allows the
+// provider to be exercised without any external git hosting, e.g. in
+// acceptance tests or air-gapped environments.
+type gitServer struct {
+	root     string
+	listener net.Listener
+	server   transport.Transport
+}
+
+var (
+	gitServersMu sync.Mutex
+	// gitServers are the started servers by address. Terraform can configure
+	// the provider several times in one process, e.g. once per alias, so
+	// servers are started once and shared rather than leaked on every
+	// configure.
+	gitServers = make(map[string]*gitServer)
+)
+
+// sharedGitServer returns the server started on address by an earlier call,
+// starting it with startGitServer otherwise. Servers sharing an address must
+// serve the same root.
+func sharedGitServer(address, root string) (*gitServer, error) {
+	gitServersMu.Lock()
+	defer gitServersMu.Unlock()
+
+	if s, ok := gitServers[address]; ok {
+		if root != "" && !sameRoot(s.root, root) {
+			return nil, fmt.Errorf("%s already serves %s", address, s.root)
+		}
+		return s, nil
+	}
+
+	s, err := startGitServer(address, root)
+	if err != nil {
+		return nil, err
+	}
+	gitServers[address] = s
+
+	return s, nil
+}
+
+// sameRoot returns whether root resolves to the directory served from.
+func sameRoot(served, root string) bool {
+	abs, err := filepath.Abs(root)
+	return err == nil && abs == served
+}
+
+// startGitServer starts serving the repositories in root on address. If root
+// is empty, a temporary directory is used.
+func startGitServer(address, root string) (*gitServer, error) {
+	if root == "" {
+		dir, err := os.MkdirTemp("", "terraform-provider-git")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		root = dir
+	}
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", root, err)
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
+	s := &gitServer{
+		root:     root,
+		listener: listener,
+	}
+	s.server = server.NewServer(s)
+
+	go http.Serve(listener, s) //nolint:errcheck
+
+	return s, nil
+}
+
+// URL returns the base URL of the server.
+func (s *gitServer) URL() string {
+	return fmt.Sprintf("http://%s", s.listener.Addr())
+}
+
+// Close stops the server.
+func (s *gitServer) Close() error {
+	return s.listener.Close()
+}
+
+// Load implements server.Loader, initializing a bare repository for paths that
+// do not exist yet.
+func (s *gitServer) Load(ep *transport.Endpoint) (storer.Storer, error) {
+	if err := checkRepoPath(ep.Path); err != nil {
+		return nil, err
+	}
+
+	path := longPath(filepath.Join(s.root, filepath.FromSlash(ep.Path)))
+
+	if _, err := os.Stat(filepath.Join(path, "config")); errors.Is(err, os.ErrNotExist) {
+		_, err := gogit.PlainInitWithOptions(path, &gogit.PlainInitOptions{
+			InitOptions: gogit.InitOptions{DefaultBranch: plumbing.Main},
+			Bare:        true,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return server.NewFilesystemLoader(osfs.New(longPath(s.root))).Load(ep)
+}
+
+func (s *gitServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/info/refs"):
+		err = s.advertiseRefs(w, r)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/"+uploadPackService):
+		err = s.uploadPack(w, r)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/"+receivePackService):
+		err = s.receivePack(w, r)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if errors.Is(err, transport.ErrRepositoryNotFound) {
+		http.NotFound(w, r)
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *gitServer) advertiseRefs(w http.ResponseWriter, r *http.Request) error {
+	service := r.URL.Query().Get("service")
+	ep, err := s.endpoint(r.URL.Path, "/info/refs")
+	if err != nil {
+		return err
+	}
+
+	var session transport.Session
+	switch service {
+	case uploadPackService:
+		session, err = s.server.NewUploadPackSession(ep, nil)
+	case receivePackService:
+		session, err = s.server.NewReceivePackSession(ep, nil)
+	default:
+		return fmt.Errorf("unsupported service %q", service)
+	}
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	refs, err := session.AdvertisedReferencesContext(r.Context())
+	if err != nil {
+		return err
+	}
+	refs.Prefix = [][]byte{
+		[]byte(fmt.Sprintf("# service=%s", service)),
+		pktline.Flush,
+	}
+
+	w.Header().Set("Content-Type", fmt.Sprintf("application/x-%s-advertisement", service))
+	w.Header().Set("Cache-Control", "no-cache")
+	return refs.Encode(w)
+}
+
+func (s *gitServer) uploadPack(w http.ResponseWriter, r *http.Request) error {
+	ep, err := s.endpoint(r.URL.Path, "/"+uploadPackService)
+	if err != nil {
+		return err
+	}
+
+	session, err := s.server.NewUploadPackSession(ep, nil)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	req := packp.NewUploadPackRequest()
+	if err := req.Decode(r.Body); err != nil {
+		return err
+	}
+
+	resp, err := session.UploadPack(r.Context(), req)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	w.Header().Set("Content-Type", fmt.Sprintf("application/x-%s-result", uploadPackService))
+	return resp.Encode(w)
+}
+
+func (s *gitServer) receivePack(w http.ResponseWriter, r *http.Request) error {
+	ep, err := s.endpoint(r.URL.Path, "/"+receivePackService)
+	if err != nil {
+		return err
+	}
+
+	session, err := s.server.NewReceivePackSession(ep, nil)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	req := packp.NewReferenceUpdateRequest()
+	if err := req.Decode(r.Body); err != nil {
+		return err
+	}
+
+	status, err := session.ReceivePack(r.Context(), req)
+	if status == nil {
+		return err
+	}
+
+	// Report the per-ref status to the client even if the update failed
+	w.Header().Set("Content-Type", fmt.Sprintf("application/x-%s-result", receivePackService))
+	return status.Encode(w)
+}
+
+// endpoint returns the repository endpoint for a request path with the given
+// service suffix.
+func (s *gitServer) endpoint(path, suffix string) (*transport.Endpoint, error) {
+	repoPath := strings.TrimSuffix(path, suffix)
+	if repoPath == "" || strings.Contains(repoPath, "..") {
+		return nil, transport.ErrRepositoryNotFound
+	}
+
+	return transport.NewEndpoint(repoPath)
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package provider
+
+// longPath returns path unchanged, as only Windows limits the path length.
+func longPath(path string) string {
+	return path
+}
+
+// checkRepoPath returns nil, as any repository path can be stored on disk.
+func checkRepoPath(path string) error {
+	return nil
+}
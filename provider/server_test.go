@@ -0,0 +1,27 @@
+package provider
+
+import (
+	"testing"
+)
+
+func TestSharedGitServer(t *testing.T) {
+	root := t.TempDir()
+
+	s, err := sharedGitServer("127.0.0.1:0", root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	again, err := sharedGitServer("127.0.0.1:0", root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != s {
+		t.Error("sharedGitServer() started a second server on the same address")
+	}
+
+	if _, err := sharedGitServer("127.0.0.1:0", t.TempDir()); err == nil {
+		t.Error("sharedGitServer() served another root on the same address")
+	}
+}
@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// reservedNames are file names Windows reserves for devices, regardless of
+// their extension.
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// longPath prefixes absolute paths with \\?\ so paths longer than MAX_PATH
+// can be used.
+func longPath(path string) string {
+	if !filepath.IsAbs(path) || strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+	if strings.HasPrefix(path, `\\`) {
+		return `\\?\UNC\` + strings.TrimPrefix(path, `\\`)
+	}
+	return `\\?\` + path
+}
+
+// checkRepoPath returns an error if a repository path cannot be stored on
+// disk, i.e. a component is a reserved device name or ends with a dot or space.
+func checkRepoPath(path string) error {
+	for _, component := range strings.Split(filepath.ToSlash(path), "/") {
+		if component == "" {
+			continue
+		}
+
+		name := strings.ToUpper(component)
+		if i := strings.Index(name, "."); i >= 0 {
+			name = name[:i]
+		}
+		if reservedNames[name] {
+			return fmt.Errorf("%s is a reserved name on Windows", component)
+		}
+		if strings.HasSuffix(component, ".") || strings.HasSuffix(component, " ") {
+			return fmt.Errorf("%s cannot end with a dot or space on Windows", component)
+		}
+	}
+
+	return nil
+}
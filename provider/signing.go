@@ -0,0 +1,136 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// readSigningKey returns the entity of an armored OpenPGP private key used to
+// sign commits, decrypting it with the passphrase if it is encrypted.
+func readSigningKey(armoredKey, passphrase string) (*openpgp.Entity, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armoredKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gpg signing key: %w", err)
+	}
+
+	var entity *openpgp.Entity
+	for _, e := range entities {
+		if e.PrivateKey != nil {
+			entity = e
+			break
+		}
+	}
+	if entity == nil {
+		return nil, fmt.Errorf("gpg signing key does not contain a private key")
+	}
+
+	if entity.PrivateKey.Encrypted {
+		if passphrase == "" {
+			return nil, fmt.Errorf("gpg signing key is encrypted: set its passphrase")
+		}
+		if err := entity.DecryptPrivateKeys([]byte(passphrase)); err != nil {
+			return nil, fmt.Errorf("failed to decrypt gpg signing key: %w", err)
+		}
+	}
+
+	return entity, nil
+}
+
+// commitSigner returns the armored signature of the encoded commit data, for
+// signatures go-git cannot make itself.
+type commitSigner func(ctx context.Context, data []byte) (string, error)
+
+// signCommit adds the signature made by sign to the commit sha and returns the
+// hash of the signed commit.
+func signCommit(ctx context.Context, repo *gogit.Repository, sha plumbing.Hash, sign commitSigner) (plumbing.Hash, error) {
+	commit, err := repo.CommitObject(sha)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to get commit %s: %w", sha, err)
+	}
+
+	encoded := &plumbing.MemoryObject{}
+	if err := commit.EncodeWithoutSignature(encoded); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to encode commit %s: %w", sha, err)
+	}
+	reader, err := encoded.Reader()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to encode commit %s: %w", sha, err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to encode commit %s: %w", sha, err)
+	}
+
+	signature, err := sign(ctx, data)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to sign commit %s: %w", sha, err)
+	}
+
+	// git stores SSH and X.509 signatures in the same header as PGP signatures
+	commit.PGPSignature = signature
+	signed := repo.Storer.NewEncodedObject()
+	if err := commit.Encode(signed); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("failed to encode signed commit: %w", err)
+	}
+
+	return repo.Storer.SetEncodedObject(signed)
+}
+
+// verifyBaseSigned returns an error unless the commit sha, if any, is signed
+// by one of the allowed_signers of a resource. Nothing is verified when no
+// allowed signers are set.
+func verifyBaseSigned(repo *gogit.Repository, sha *plumbing.Hash, allowedSigners []interface{}) error {
+	if len(allowedSigners) == 0 || sha == nil {
+		return nil
+	}
+
+	var keys []string
+	for _, key := range allowedSigners {
+		keys = append(keys, key.(string))
+	}
+
+	return verifyBase(repo, *sha, keys)
+}
+
+// verifyBase returns an error unless the commit sha has a valid PGP signature
+// made by one of the armored public keys.
+func verifyBase(repo *gogit.Repository, sha plumbing.Hash, armoredKeys []string) error {
+	commit, err := repo.CommitObject(sha)
+	if err != nil {
+		return fmt.Errorf("failed to get commit %s: %w", sha, err)
+	}
+
+	if commit.PGPSignature == "" {
+		return fmt.Errorf("commit %s is not signed", sha)
+	}
+
+	var keyring openpgp.EntityList
+	for _, key := range armoredKeys {
+		entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+		if err != nil {
+			return fmt.Errorf("failed to read allowed signer key: %w", err)
+		}
+		keyring = append(keyring, entities...)
+	}
+
+	encoded := &plumbing.MemoryObject{}
+	if err := commit.EncodeWithoutSignature(encoded); err != nil {
+		return fmt.Errorf("failed to encode commit %s: %w", sha, err)
+	}
+	reader, err := encoded.Reader()
+	if err != nil {
+		return fmt.Errorf("failed to encode commit %s: %w", sha, err)
+	}
+
+	if _, err := openpgp.CheckArmoredDetachedSignature(keyring, reader, strings.NewReader(commit.PGPSignature), nil); err != nil {
+		return fmt.Errorf("commit %s is not signed by an allowed signer: %w", sha, err)
+	}
+
+	return nil
+}
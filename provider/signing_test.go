@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/go-git/go-billy/v5/memfs"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestVerifyBaseSigned(t *testing.T) {
+	signer := newTestEntity(t)
+	other := newTestEntity(t)
+
+	repo, err := gogit.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := func(key *openpgp.Entity) *plumbing.Hash {
+		sha, err := worktree.Commit("test", &gogit.CommitOptions{
+			AllowEmptyCommits: true,
+			Author:            &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+			SignKey:           key,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &sha
+	}
+	signed, unsigned := commit(signer), commit(nil)
+
+	tests := []struct {
+		name           string
+		sha            *plumbing.Hash
+		allowedSigners []interface{}
+		wantErr        bool
+	}{
+		{name: "no allowed signers", sha: unsigned},
+		{name: "new branch", allowedSigners: []interface{}{armoredPublicKey(t, signer)}},
+		{name: "allowed signer", sha: signed, allowedSigners: []interface{}{armoredPublicKey(t, other), armoredPublicKey(t, signer)}},
+		{name: "other signer", sha: signed, allowedSigners: []interface{}{armoredPublicKey(t, other)}, wantErr: true},
+		{name: "unsigned", sha: unsigned, allowedSigners: []interface{}{armoredPublicKey(t, signer)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyBaseSigned(repo, tt.sha, tt.allowedSigners)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyBaseSigned() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func newTestEntity(t *testing.T) *openpgp.Entity {
+	t.Helper()
+
+	entity, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entity
+}
+
+func armoredPublicKey(t *testing.T, entity *openpgp.Entity) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestCommitOptionsSigningKeyFile(t *testing.T) {
+	entity := newTestEntity(t)
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.SerializePrivate(w, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "signing.asc")
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := gogit.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := schema.TestResourceDataRaw(t, resourceCommit().Schema, map[string]interface{}{"gpg_signing_key_file": path})
+	opts, err := commitOptions(d, &providerConfig{signKey: newTestEntity(t)}, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.SignKey == nil || opts.SignKey.PrimaryKey.KeyId != entity.PrimaryKey.KeyId {
+		t.Errorf("SignKey = %v, want the key of gpg_signing_key_file", opts.SignKey)
+	}
+
+	d = schema.TestResourceDataRaw(t, resourceCommit().Schema, map[string]interface{}{"gpg_signing_key_file": filepath.Join(t.TempDir(), "missing.asc")})
+	if _, err := commitOptions(d, &providerConfig{}, repo); err == nil {
+		t.Error("commitOptions() error = nil, want an error reading gpg_signing_key_file")
+	}
+}
@@ -0,0 +1,440 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// The public good instances of Sigstore.
+const (
+	defaultFulcioURL = "https://fulcio.sigstore.dev"
+	defaultRekorURL  = "https://rekor.sigstore.dev"
+)
+
+// sigstoreAudience is the audience of the OIDC tokens Fulcio accepts.
+const sigstoreAudience = "sigstore"
+
+var (
+	oidData            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidSHA256          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+// sigstoreSigner signs commits keylessly like gitsign: every commit is signed
+// with an ephemeral key, certified by Fulcio for the identity of an OIDC
+// token, and the signature is recorded in the Rekor transparency log so it
+// can be verified after the short-lived certificate expires.
+type sigstoreSigner struct {
+	fulcioURL string
+	rekorURL  string
+	// idToken is the configured OIDC token. When empty, an ambient token of
+	// the CI system is used.
+	idToken string
+	client  *http.Client
+
+	mu     sync.Mutex
+	issued []string
+}
+
+// oidcToken returns the OIDC token identifying the signer: the configured
+// one, the one in SIGSTORE_ID_TOKEN, as set by GitLab CI id_tokens, or one
+// requested for the running GitHub Actions job.
+func (s *sigstoreSigner) oidcToken(ctx context.Context) (string, error) {
+	if s.idToken != "" {
+		return s.idToken, nil
+	}
+	if token := os.Getenv("SIGSTORE_ID_TOKEN"); token != "" {
+		return token, nil
+	}
+	if os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL") != "" {
+		oidc := &githubActionsOIDC{audience: sigstoreAudience, client: s.client}
+		return oidc.idToken(ctx)
+	}
+
+	return "", fmt.Errorf("no OIDC token available: set sigstore id_token, or SIGSTORE_ID_TOKEN in CI")
+}
+
+// tokens returns the OIDC tokens commits were signed with, so they can be
+// redacted.
+func (s *sigstoreSigner) tokens() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.issued...)
+}
+
+// sign implements commitSigner, returning the commit signature as a detached
+// CMS signature like the ones made by gitsign.
+func (s *sigstoreSigner) sign(ctx context.Context, data []byte) (string, error) {
+	token, err := s.oidcToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.issued = append(s.issued, token)
+	s.mu.Unlock()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", err
+	}
+
+	chain, err := s.signingCertificate(ctx, token, key)
+	if err != nil {
+		return "", err
+	}
+
+	digest := sha256.Sum256(data)
+	signedAttrs, err := cmsSignedAttributes(digest[:], time.Now())
+	if err != nil {
+		return "", err
+	}
+	signedDigest := sha256.Sum256(signedAttrs)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, signedDigest[:])
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.recordSignature(ctx, chain[0], signedDigest[:], signature); err != nil {
+		return "", err
+	}
+
+	cms, err := cmsSignedData(chain, signedAttrs, signature)
+	if err != nil {
+		return "", err
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "SIGNED MESSAGE", Bytes: cms})), nil
+}
+
+// signingCertificate requests a certificate for the public key of key from
+// Fulcio, returning the certificate chain starting with the certificate.
+func (s *sigstoreSigner) signingCertificate(ctx context.Context, token string, key *ecdsa.PrivateKey) ([]*x509.Certificate, error) {
+	subject, err := tokenSubject(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fulcio requires proving possession of the key by signing the subject
+	subjectDigest := sha256.Sum256([]byte(subject))
+	proof, err := ecdsa.SignASN1(rand.Reader, key, subjectDigest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var request struct {
+		Credentials struct {
+			OIDCIdentityToken string `json:"oidcIdentityToken"`
+		} `json:"credentials"`
+		PublicKeyRequest struct {
+			PublicKey struct {
+				Algorithm string `json:"algorithm"`
+				Content   string `json:"content"`
+			} `json:"publicKey"`
+			ProofOfPossession []byte `json:"proofOfPossession"`
+		} `json:"publicKeyRequest"`
+	}
+	request.Credentials.OIDCIdentityToken = token
+	request.PublicKeyRequest.PublicKey.Algorithm = "ECDSA"
+	request.PublicKeyRequest.PublicKey.Content = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}))
+	request.PublicKeyRequest.ProofOfPossession = proof
+
+	type certificateChain struct {
+		Chain struct {
+			Certificates []string `json:"certificates"`
+		} `json:"chain"`
+	}
+	var response struct {
+		EmbeddedSCT *certificateChain `json:"signedCertificateEmbeddedSct"`
+		DetachedSCT *certificateChain `json:"signedCertificateDetachedSct"`
+	}
+	if err := postJSON(ctx, s.client, strings.TrimSuffix(s.fulcioURL, "/")+"/api/v2/signingCert", request, &response); err != nil {
+		return nil, fmt.Errorf("failed to get signing certificate from Fulcio: %w", err)
+	}
+
+	certificates := response.EmbeddedSCT
+	if certificates == nil {
+		certificates = response.DetachedSCT
+	}
+	if certificates == nil || len(certificates.Chain.Certificates) == 0 {
+		return nil, fmt.Errorf("failed to get signing certificate from Fulcio: no certificate returned")
+	}
+
+	var chain []*x509.Certificate
+	for _, encoded := range certificates.Chain.Certificates {
+		block, _ := pem.Decode([]byte(encoded))
+		if block == nil {
+			return nil, fmt.Errorf("failed to decode Fulcio certificate")
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Fulcio certificate: %w", err)
+		}
+		chain = append(chain, certificate)
+	}
+
+	return chain, nil
+}
+
+// recordSignature adds the signature of digest to the Rekor transparency log.
+func (s *sigstoreSigner) recordSignature(ctx context.Context, certificate *x509.Certificate, digest, signature []byte) error {
+	certificatePEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})
+
+	entry := map[string]interface{}{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]interface{}{
+			"signature": map[string]interface{}{
+				"content": signature,
+				"publicKey": map[string]interface{}{
+					"content": certificatePEM,
+				},
+			},
+			"data": map[string]interface{}{
+				"hash": map[string]interface{}{
+					"algorithm": "sha256",
+					"value":     hex.EncodeToString(digest),
+				},
+			},
+		},
+	}
+
+	var response map[string]struct {
+		LogIndex int64 `json:"logIndex"`
+	}
+	if err := postJSON(ctx, s.client, strings.TrimSuffix(s.rekorURL, "/")+"/api/v1/log/entries", entry, &response); err != nil {
+		return fmt.Errorf("failed to record signature in Rekor: %w", err)
+	}
+
+	for uuid, logEntry := range response {
+		tflog.Info(ctx, "recorded commit signature in Rekor", map[string]interface{}{
+			"uuid":      uuid,
+			"log_index": logEntry.LogIndex,
+		})
+	}
+
+	return nil
+}
+
+// tokenSubject returns the subject Fulcio certifies for the OIDC token: its
+// email if it has one, and its subject otherwise. The token is verified by
+// Fulcio, not here.
+func tokenSubject(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("invalid OIDC token: not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid OIDC token: %w", err)
+	}
+
+	var claims struct {
+		Subject string `json:"sub"`
+		Email   string `json:"email"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", fmt.Errorf("invalid OIDC token: %w", err)
+	}
+	if claims.Email != "" {
+		return claims.Email, nil
+	}
+	if claims.Subject == "" {
+		return "", fmt.Errorf("invalid OIDC token: no subject")
+	}
+
+	return claims.Subject, nil
+}
+
+// postJSON posts request to rawURL as JSON with client and decodes the JSON
+// response into response.
+func postJSON(ctx context.Context, client *http.Client, rawURL string, request, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type signerInfo struct {
+	Version            int
+	SID                issuerAndSerialNumber
+	DigestAlgorithm    algorithmIdentifier
+	SignedAttrs        asn1.RawValue
+	SignatureAlgorithm algorithmIdentifier
+	Signature          []byte
+}
+
+type encapsulatedContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []algorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue
+	SignerInfos      []signerInfo `asn1:"set"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+// cmsSignedAttributes returns the DER encoded set of CMS signed attributes of
+// the content with the digest. These, rather than the content, are signed.
+func cmsSignedAttributes(digest []byte, signingTime time.Time) ([]byte, error) {
+	values := []struct {
+		oid   asn1.ObjectIdentifier
+		value interface{}
+	}{
+		{oidContentType, oidData},
+		{oidSigningTime, signingTime.UTC()},
+		{oidMessageDigest, digest},
+	}
+
+	var attributes [][]byte
+	for _, v := range values {
+		value, err := asn1.Marshal(v.value)
+		if err != nil {
+			return nil, err
+		}
+		attribute, err := asn1.Marshal(struct {
+			Type   asn1.ObjectIdentifier
+			Values []asn1.RawValue `asn1:"set"`
+		}{v.oid, []asn1.RawValue{{FullBytes: value}}})
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, attribute)
+	}
+
+	// DER sorts the elements of a set by their encoding
+	sort.Slice(attributes, func(i, j int) bool {
+		return bytes.Compare(attributes[i], attributes[j]) < 0
+	})
+
+	return asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSet,
+		IsCompound: true,
+		Bytes:      bytes.Join(attributes, nil),
+	})
+}
+
+// cmsSignedData returns the DER encoded detached CMS signature of the signed
+// attributes by the first certificate of chain.
+func cmsSignedData(chain []*x509.Certificate, signedAttrs, signature []byte) ([]byte, error) {
+	var attrs asn1.RawValue
+	if _, err := asn1.Unmarshal(signedAttrs, &attrs); err != nil {
+		return nil, err
+	}
+
+	var certificates []byte
+	for _, certificate := range chain {
+		certificates = append(certificates, certificate.Raw...)
+	}
+
+	digestAlgorithm := algorithmIdentifier{Algorithm: oidSHA256}
+	data, err := asn1.Marshal(signedData{
+		Version:          1,
+		DigestAlgorithms: []algorithmIdentifier{digestAlgorithm},
+		EncapContentInfo: encapsulatedContentInfo{ContentType: oidData},
+		Certificates: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      certificates,
+		},
+		SignerInfos: []signerInfo{{
+			Version: 1,
+			SID: issuerAndSerialNumber{
+				Issuer:       asn1.RawValue{FullBytes: chain[0].RawIssuer},
+				SerialNumber: chain[0].SerialNumber,
+			},
+			DigestAlgorithm: digestAlgorithm,
+			// The signed attributes are implicitly tagged in the signer info
+			SignedAttrs: asn1.RawValue{
+				Class:      asn1.ClassContextSpecific,
+				Tag:        0,
+				IsCompound: true,
+				Bytes:      attrs.Bytes,
+			},
+			SignatureAlgorithm: algorithmIdentifier{Algorithm: oidECDSAWithSHA256},
+			Signature:          signature,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      data,
+		},
+	})
+}
@@ -0,0 +1,197 @@
+package provider
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCMSSignedAttributes(t *testing.T) {
+	digest := make([]byte, sha256.Size)
+	for i := range digest {
+		digest[i] = byte(i)
+	}
+
+	attrs, err := cmsSignedAttributes(digest, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The DER set of the contentType, signingTime and messageDigest
+	// attributes, sorted by their encoding
+	want := "3169" +
+		"301806092a864886f70d010903310b06092a864886f70d010701" +
+		"301c06092a864886f70d010905310f170d3234303130323033303430355a" +
+		"302f06092a864886f70d01090431220420000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	if got := hex.EncodeToString(attrs); got != want {
+		t.Errorf("cmsSignedAttributes() = %s, want %s", got, want)
+	}
+}
+
+func TestSigstoreSign(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test fulcio"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fulcio := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			PublicKeyRequest struct {
+				PublicKey struct {
+					Content string `json:"content"`
+				} `json:"publicKey"`
+				ProofOfPossession []byte `json:"proofOfPossession"`
+			} `json:"publicKeyRequest"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		block, _ := pem.Decode([]byte(request.PublicKeyRequest.PublicKey.Content))
+		if block == nil {
+			http.Error(w, "invalid public key", http.StatusBadRequest)
+			return
+		}
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		subjectDigest := sha256.Sum256([]byte("signer@example.com"))
+		if !ecdsa.VerifyASN1(publicKey.(*ecdsa.PublicKey), subjectDigest[:], request.PublicKeyRequest.ProofOfPossession) {
+			http.Error(w, "invalid proof of possession", http.StatusBadRequest)
+			return
+		}
+
+		leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber:   big.NewInt(2),
+			NotBefore:      time.Now().Add(-time.Minute),
+			NotAfter:       time.Now().Add(10 * time.Minute),
+			KeyUsage:       x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+			EmailAddresses: []string{"signer@example.com"},
+		}, ca, publicKey, caKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var response struct {
+			EmbeddedSCT struct {
+				Chain struct {
+					Certificates []string `json:"certificates"`
+				} `json:"chain"`
+			} `json:"signedCertificateEmbeddedSct"`
+		}
+		response.EmbeddedSCT.Chain.Certificates = []string{
+			string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})),
+			string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response) //nolint:errcheck
+	}))
+	defer fulcio.Close()
+
+	var recorded atomic.Int32
+	rekor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorded.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"0123": {"logIndex": 1}}`)) //nolint:errcheck
+	}))
+	defer rekor.Close()
+
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"1234","email":"signer@example.com"}`))
+	signer := &sigstoreSigner{
+		fulcioURL: fulcio.URL,
+		rekorURL:  rekor.URL,
+		idToken:   "e30." + claims + ".c2ln",
+		client:    http.DefaultClient,
+	}
+
+	data := []byte("tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n\nTest commit\n")
+	signature, err := signer.sign(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := recorded.Load(); n != 1 {
+		t.Errorf("recorded %d signatures in Rekor, want 1", n)
+	}
+	if tokens := signer.tokens(); len(tokens) != 1 || tokens[0] != signer.idToken {
+		t.Errorf("tokens() = %v, want the OIDC token", tokens)
+	}
+
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl is not installed")
+	}
+	// git stores the signature as a SIGNED MESSAGE PEM block, which openssl
+	// does not read, so it is verified as DER
+	block, _ := pem.Decode([]byte(signature))
+	if block == nil || block.Type != "SIGNED MESSAGE" {
+		t.Fatalf("signature = %q, want a SIGNED MESSAGE PEM block", signature)
+	}
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"commit":        data,
+		"signature.der": block.Bytes,
+		"ca.pem":        pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(openssl, "cms", "-verify", "-binary", "-inform", "DER",
+		"-in", "signature.der", "-content", "commit", "-CAfile", "ca.pem", "-purpose", "any", "-out", os.DevNull)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("openssl cms -verify: %s\n%s", err, output)
+	}
+
+	// A signature of other content must not verify
+	if err := os.WriteFile(filepath.Join(dir, "commit"), []byte("tampered"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cmd = exec.Command(openssl, "cms", "-verify", "-binary", "-inform", "DER",
+		"-in", "signature.der", "-content", "commit", "-CAfile", "ca.pem", "-purpose", "any", "-out", os.DevNull)
+	cmd.Dir = dir
+	if err := cmd.Run(); err == nil {
+		t.Error("openssl cms -verify succeeded for other content")
+	}
+}
@@ -0,0 +1,217 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/util"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+func resourceCommitSourceDir() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"source": {
+				Description:  "The path of the local directory, e.g. `\"${path.module}/chart\"`.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"path": {
+				Description:  "The directory of the repository the files are copied to. Defaults to the root of the repository.",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validateRepoPath,
+			},
+			"include": {
+				Description: "Patterns of the files to copy, relative to `source`, with the syntax of `.gitignore`, e.g. `*.yaml` or `templates/**`. Defaults to all files.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringIsNotEmpty,
+				},
+			},
+			"exclude": {
+				Description: "Patterns of the files not to copy, relative to `source`, with the syntax of `.gitignore`.",
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringIsNotEmpty,
+				},
+			},
+		},
+	}
+}
+
+// commitItems returns the add items of the resource, followed by an add item
+// for every file of its source_dir blocks.
+func commitItems(d *schema.ResourceData) ([]interface{}, error) {
+	items := d.Get("add").(*schema.Set).List()
+
+	dirItems, err := sourceDirItems(d.Get("source_dir").([]interface{}))
+	if err != nil {
+		return nil, err
+	}
+
+	return append(items, dirItems...), nil
+}
+
+// sourceDirItems returns an add item for every file of the source_dir blocks,
+// read from its source_file when it is written. Executable files stay
+// executable.
+func sourceDirItems(blocks []interface{}) ([]interface{}, error) {
+	defaults := addItemDefaults()
+	var items []interface{}
+	for _, block := range blocks {
+		source := block.(map[string]interface{})["source"].(string)
+		target := block.(map[string]interface{})["path"].(string)
+		include := globs(block.(map[string]interface{})["include"].([]interface{}))
+		exclude := globs(block.(map[string]interface{})["exclude"].([]interface{}))
+
+		err := filepath.WalkDir(source, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+
+			rel, err := filepath.Rel(source, file)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if (len(include) > 0 && !matchesGlobs(include, rel)) || matchesGlobs(exclude, rel) {
+				return nil
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+
+			item := make(map[string]interface{}, len(defaults))
+			for key, value := range defaults {
+				item[key] = value
+			}
+			item["path"] = path.Join(target, rel)
+			item["source_file"] = file
+			item["executable"] = info.Mode()&0111 != 0
+			items = append(items, item)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read source_dir %s: %w", source, err)
+		}
+	}
+
+	return items, nil
+}
+
+// addItemDefaults returns the value of every attribute of an add item that
+// is not set, so items built from source_dir blocks read like add blocks.
+func addItemDefaults() map[string]interface{} {
+	defaults := make(map[string]interface{})
+	for key, attr := range resourceCommitAdd().Schema {
+		if attr.Default != nil {
+			defaults[key] = attr.Default
+		} else {
+			defaults[key] = attr.ZeroValue()
+		}
+	}
+
+	return defaults
+}
+
+// itemPaths returns the cleaned paths of the add items.
+func itemPaths(items []interface{}) map[string]bool {
+	paths := make(map[string]bool, len(items))
+	for _, item := range items {
+		paths[path.Clean(filepath.ToSlash(item.(map[string]interface{})["path"].(string)))] = true
+	}
+
+	return paths
+}
+
+// pruneSourceDirs removes the files of worktree in the path of the source_dir
+// blocks that match their patterns, except the ones in keep.
+func pruneSourceDirs(worktree *gogit.Worktree, blocks []interface{}, keep map[string]bool) error {
+	for _, block := range blocks {
+		target := block.(map[string]interface{})["path"].(string)
+		include := globs(block.(map[string]interface{})["include"].([]interface{}))
+		exclude := globs(block.(map[string]interface{})["exclude"].([]interface{}))
+
+		root, prefix := "/", ""
+		if target != "" {
+			root = path.Clean(target)
+			prefix = root + "/"
+		}
+		var files []string
+		err := util.Walk(worktree.Filesystem, root, func(file string, info os.FileInfo, err error) error {
+			if errors.Is(err, os.ErrNotExist) {
+				return filepath.SkipDir
+			} else if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if info.Name() == gogit.GitDirName {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			file = strings.TrimPrefix(filepath.ToSlash(file), "/")
+			rel := strings.TrimPrefix(file, prefix)
+			if keep[file] || (len(include) > 0 && !matchesGlobs(include, rel)) || matchesGlobs(exclude, rel) {
+				return nil
+			}
+			files = append(files, file)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list files of %s: %w", root, err)
+		}
+
+		for _, file := range files {
+			if _, err := worktree.Remove(file); err != nil && !errors.Is(err, index.ErrEntryNotFound) {
+				return fmt.Errorf("failed to delete file %s: %w", file, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// globs returns the patterns of a list attribute.
+func globs(items []interface{}) []string {
+	patterns := make([]string, 0, len(items))
+	for _, item := range items {
+		patterns = append(patterns, item.(string))
+	}
+
+	return patterns
+}
+
+// matchesGlobs reports whether the slash separated path matches one of the
+// patterns, which have the syntax of .gitignore.
+func matchesGlobs(patterns []string, file string) bool {
+	parts := strings.Split(file, "/")
+	for _, pattern := range patterns {
+		if gitignore.ParsePattern(pattern, nil).Match(parts, false) == gitignore.Exclude {
+			return true
+		}
+	}
+
+	return false
+}
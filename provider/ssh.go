@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshUser is the user git hosts expect for SSH access.
+const sshUser = "git"
+
+// sshAuth returns the auth method for SSH URLs. The private key is used when
+// set, decrypted with the passphrase if it is encrypted, otherwise the keys of
+// the running SSH agent are. Host keys are checked against the given
+// known_hosts content and files, or the default known_hosts files when neither
+// is set, unless host key checking is disabled.
+func sshAuth(privateKey, passphrase, knownHosts string, knownHostsFiles []string, hostKeyChecking bool) (transport.AuthMethod, error) {
+	callback, err := hostKeyCallback(knownHosts, knownHostsFiles, hostKeyChecking)
+	if err != nil {
+		return nil, err
+	}
+
+	if privateKey != "" {
+		if passphrase == "" {
+			var passphraseErr *ssh.PassphraseMissingError
+			if _, err := ssh.ParseRawPrivateKey([]byte(privateKey)); errors.As(err, &passphraseErr) {
+				return nil, fmt.Errorf("ssh private key is encrypted: set ssh_private_key_passphrase")
+			}
+		}
+
+		auth, err := gitssh.NewPublicKeys(sshUser, []byte(privateKey), passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ssh private key: %w", err)
+		}
+		auth.HostKeyCallback = callback
+		return auth, nil
+	}
+
+	auth, err := gitssh.NewSSHAgentAuth(sshUser)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ssh agent: %w", err)
+	}
+	auth.HostKeyCallback = callback
+	return auth, nil
+}
+
+// hostKeyCallback returns the callback verifying the host keys of SSH
+// servers.
+func hostKeyCallback(knownHosts string, knownHostsFiles []string, hostKeyChecking bool) (ssh.HostKeyCallback, error) {
+	if !hostKeyChecking {
+		return ssh.InsecureIgnoreHostKey(), nil //nolint:gosec
+	}
+
+	files := knownHostsFiles
+	if knownHosts != "" {
+		// The known_hosts parser only reads files
+		file, err := os.CreateTemp("", "known_hosts")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create known_hosts file: %w", err)
+		}
+		defer os.Remove(file.Name())
+		defer file.Close()
+
+		if _, err := file.WriteString(knownHosts); err != nil {
+			return nil, fmt.Errorf("failed to write known_hosts file: %w", err)
+		}
+		files = append(files, file.Name())
+	}
+
+	callback, err := gitssh.NewKnownHostsCallback(files...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts: %w", err)
+	}
+
+	return callback, nil
+}
+
+// proxiedSSHAuth is the SSH auth method of a provider configuration
+// connecting through a proxy, e.g. a jump host. The auth method may be nil to
+// use the default SSH auth of go-git.
+type proxiedSSHAuth struct {
+	auth     transport.AuthMethod
+	proxyURL string
+}
+
+func (a *proxiedSSHAuth) Name() string {
+	if a.auth == nil {
+		return "ssh-proxy"
+	}
+	return a.auth.Name()
+}
+
+func (a *proxiedSSHAuth) String() string {
+	if a.auth == nil {
+		return "ssh-proxy"
+	}
+	return a.auth.String()
+}
+
+// withSSHProxy returns auth connecting through the proxy, or auth itself
+// when no proxy is set.
+func withSSHProxy(auth transport.AuthMethod, proxyURL string) transport.AuthMethod {
+	if proxyURL == "" {
+		return auth
+	}
+	return &proxiedSSHAuth{auth: auth, proxyURL: proxyURL}
+}
+
+// installSSHTransportOnce installs the SSH transport of go-git once per
+// process. Transports are global, so the proxy of each provider configuration
+// is carried by its auth method instead.
+var installSSHTransportOnce sync.Once
+
+// installSSHTransport makes go-git connect to SSH servers through the proxy
+// of the auth method of each session, if any.
+func installSSHTransport() {
+	installSSHTransportOnce.Do(func() {
+		client.InstallProtocol("ssh", proxiedSSHTransport{})
+	})
+}
+
+// proxiedSSHTransport is the SSH transport of go-git, connecting through the
+// proxy of proxiedSSHAuth auth methods.
+type proxiedSSHTransport struct{}
+
+func (proxiedSSHTransport) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	ep, auth = proxiedEndpoint(ep, auth)
+	return gitssh.DefaultClient.NewUploadPackSession(ep, auth)
+}
+
+func (proxiedSSHTransport) NewReceivePackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.ReceivePackSession, error) {
+	ep, auth = proxiedEndpoint(ep, auth)
+	return gitssh.DefaultClient.NewReceivePackSession(ep, auth)
+}
+
+// proxiedEndpoint returns a copy of ep using the proxy of auth, and the SSH
+// auth method to connect with.
+func proxiedEndpoint(ep *transport.Endpoint, auth transport.AuthMethod) (*transport.Endpoint, transport.AuthMethod) {
+	proxied, ok := auth.(*proxiedSSHAuth)
+	if !ok {
+		return ep, auth
+	}
+
+	endpoint := *ep
+	endpoint.Proxy = transport.ProxyOptions{URL: proxied.proxyURL}
+	return &endpoint, proxied.auth
+}
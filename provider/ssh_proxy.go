@@ -0,0 +1,240 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/proxy"
+)
+
+// sshDialerScheme is the scheme of the proxy URLs of registered SSH dialers.
+// go-git only lets connections to SSH servers be customized through a proxy
+// URL, so jump hosts and proxy commands are registered as proxy dialers.
+const sshDialerScheme = "git-provider-dialer"
+
+var (
+	sshDialersOnce sync.Once
+	sshDialers     sync.Map
+	sshDialerIDs   atomic.Uint64
+)
+
+// registerSSHDialer registers the dialer of a provider configuration as a
+// proxy dialer, returning the proxy URL selecting it. The dialer type is
+// registered once, and each configuration gets its own proxy URL.
+func registerSSHDialer(dialer proxy.ContextDialer) string {
+	sshDialersOnce.Do(func() {
+		proxy.RegisterDialerType(sshDialerScheme, func(u *url.URL, _ proxy.Dialer) (proxy.Dialer, error) {
+			dialer, ok := sshDialers.Load(u.Host)
+			if !ok {
+				return nil, fmt.Errorf("unknown ssh dialer %s", u.Host)
+			}
+			return dialer.(proxy.Dialer), nil
+		})
+	})
+
+	id := strconv.FormatUint(sshDialerIDs.Add(1), 10)
+	sshDialers.Store(id, dialer)
+	return fmt.Sprintf("%s://%s", sshDialerScheme, id)
+}
+
+// sshJumpDialer connects to SSH servers through a jump host, like ssh's
+// ProxyJump.
+type sshJumpDialer struct {
+	addr   string
+	config *ssh.ClientConfig
+}
+
+// newSSHJumpDialer returns a dialer connecting through the jump host given
+// as [user@]host[:port], authenticating with auth. The user defaults to the
+// current user.
+func newSSHJumpDialer(jump string, auth transport.AuthMethod) (*sshJumpDialer, error) {
+	sshAuth, ok := auth.(gitssh.AuthMethod)
+	if !ok {
+		return nil, fmt.Errorf("ssh_proxy_jump requires SSH auth")
+	}
+	config, err := sshAuth.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	user, host, ok := strings.Cut(jump, "@")
+	if !ok {
+		host, user = user, os.Getenv("USER")
+		if user == "" {
+			user = os.Getenv("USERNAME")
+		}
+	}
+	if host == "" || user == "" {
+		return nil, fmt.Errorf("invalid ssh_proxy_jump %s: expected [user@]host[:port]", jump)
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "22")
+	}
+
+	jumpConfig := *config
+	jumpConfig.User = user
+	return &sshJumpDialer{
+		addr:   host,
+		config: &jumpConfig,
+	}, nil
+}
+
+func (d *sshJumpDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *sshJumpDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", d.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to jump host %s: %w", d.addr, err)
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, d.addr, d.config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to jump host %s: %w", d.addr, err)
+	}
+	client := ssh.NewClient(c, chans, reqs)
+
+	target, err := client.Dial(network, addr)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to %s through jump host %s: %w", addr, d.addr, err)
+	}
+
+	return &jumpConn{Conn: target, client: client}, nil
+}
+
+// jumpConn is a connection through a jump host, closing the connection to
+// the jump host with it.
+type jumpConn struct {
+	net.Conn
+	client *ssh.Client
+}
+
+func (c *jumpConn) Close() error {
+	err := c.Conn.Close()
+	c.client.Close()
+	return err
+}
+
+// sshProxyCommandDialer connects to SSH servers through the stdin and stdout
+// of a command, like ssh's ProxyCommand.
+type sshProxyCommandDialer struct {
+	command string
+}
+
+func (d *sshProxyCommandDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *sshProxyCommandDialer) DialContext(_ context.Context, _, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	command := strings.NewReplacer("%%", "%", "%h", host, "%p", port).Replace(d.command)
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	conn := &commandConn{cmd: cmd, stdin: stdin, stdout: stdout}
+	cmd.Stderr = &conn.stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ssh_proxy_command: %w", err)
+	}
+
+	return conn, nil
+}
+
+// commandConn is a connection over the stdin and stdout of a command.
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	stderr lockedBuffer
+}
+
+// lockedBuffer is a buffer safe for concurrent use, as the output of a
+// command is written while it runs.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (c *commandConn) Read(b []byte) (int, error) {
+	n, err := c.stdout.Read(b)
+	if stderr := strings.TrimSpace(c.stderr.String()); err == io.EOF && stderr != "" {
+		return n, fmt.Errorf("ssh_proxy_command exited: %s", stderr)
+	}
+	return n, err
+}
+
+func (c *commandConn) Write(b []byte) (int, error) {
+	return c.stdin.Write(b)
+}
+
+func (c *commandConn) Close() error {
+	c.stdin.Close()
+	_ = c.cmd.Process.Kill()
+	// Children of the command may keep its output open, so it is not waited
+	// for before returning
+	go c.cmd.Wait() //nolint:errcheck
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr  { return commandAddr(c.cmd.Path) }
+func (c *commandConn) RemoteAddr() net.Addr { return commandAddr(c.cmd.Path) }
+
+// Deadlines are not supported by pipes to a process, and not needed by the
+// SSH client.
+func (c *commandConn) SetDeadline(time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(time.Time) error { return nil }
+
+// commandAddr is the address of a connection to a command.
+type commandAddr string
+
+func (a commandAddr) Network() string { return "command" }
+func (a commandAddr) String() string  { return string(a) }
@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"golang.org/x/net/proxy"
+)
+
+func TestSSHProxyPerConfiguration(t *testing.T) {
+	configure := func(raw map[string]interface{}) *providerConfig {
+		t.Helper()
+		cfg, diags := newProviderConfig(context.Background(), schema.TestResourceDataRaw(t, Provider().Schema, raw))
+		if diags.HasError() {
+			t.Fatal(diags)
+		}
+		return cfg
+	}
+	first := configure(map[string]interface{}{"ssh_proxy_command": "nc %h %p"})
+	second := configure(map[string]interface{}{"ssh_proxy_command": "ssh -W %h:%p bastion"})
+	direct := configure(map[string]interface{}{"token": "secret"})
+
+	ep, err := transport.NewEndpoint("ssh://git@example.com/org/repo.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tt := range map[string]struct {
+		cfg         *providerConfig
+		wantCommand string
+	}{
+		"first":  {cfg: first, wantCommand: "nc %h %p"},
+		"second": {cfg: second, wantCommand: "ssh -W %h:%p bastion"},
+		"direct": {cfg: direct},
+	} {
+		t.Run(name, func(t *testing.T) {
+			proxied, auth := proxiedEndpoint(ep, tt.cfg.authFor(ep.String()))
+			if auth != nil {
+				t.Errorf("auth = %v, want the default SSH auth", auth)
+			}
+			if tt.wantCommand == "" {
+				if proxied.Proxy.URL != "" {
+					t.Errorf("proxy = %s, want none", proxied.Proxy.URL)
+				}
+				return
+			}
+
+			u, err := url.Parse(proxied.Proxy.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dialer, err := proxy.FromURL(u, proxy.Direct)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if command, ok := dialer.(*sshProxyCommandDialer); !ok || command.command != tt.wantCommand {
+				t.Errorf("dialer = %#v, want the proxy command %s", dialer, tt.wantCommand)
+			}
+		})
+	}
+}
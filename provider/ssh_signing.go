@@ -0,0 +1,90 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// sshSignatureNamespace is the namespace git signs commits in.
+	sshSignatureNamespace = "git"
+	// sshSignatureMagic starts the signed data and blob of SSH signatures.
+	sshSignatureMagic = "SSHSIG"
+)
+
+// readSSHSigningKey parses the SSH private key used to sign commits,
+// decrypting it with the passphrase if it is encrypted.
+func readSSHSigningKey(privateKey, passphrase string) (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(privateKey))
+	var passphraseErr *ssh.PassphraseMissingError
+	if errors.As(err, &passphraseErr) {
+		if passphrase == "" {
+			return nil, fmt.Errorf("ssh signing key is encrypted: set its passphrase")
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(privateKey), []byte(passphrase))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ssh signing key: %w", err)
+	}
+
+	return signer, nil
+}
+
+// sshCommitSigner returns the commitSigner signing commits with signer, in
+// the format of `ssh-keygen -Y sign` used by git.
+func sshCommitSigner(signer ssh.Signer) commitSigner {
+	return func(_ context.Context, data []byte) (string, error) {
+		return sshSignature(data, signer)
+	}
+}
+
+// sshSignature returns the armored SSH signature of data, as described in
+// PROTOCOL.sshsig of OpenSSH.
+func sshSignature(data []byte, signer ssh.Signer) (string, error) {
+	hash := sha512.Sum512(data)
+	signedData := append([]byte(sshSignatureMagic), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{sshSignatureNamespace, "", "sha512", hash[:]})...)
+
+	var signature *ssh.Signature
+	var err error
+	if algorithmSigner, ok := signer.(ssh.AlgorithmSigner); ok && signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		// SHA-1 RSA signatures are not accepted
+		signature, err = algorithmSigner.SignWithAlgorithm(rand.Reader, signedData, ssh.KeyAlgoRSASHA512)
+	} else {
+		signature, err = signer.Sign(rand.Reader, signedData)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	blob := append([]byte(sshSignatureMagic), ssh.Marshal(struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}{1, signer.PublicKey().Marshal(), sshSignatureNamespace, "", "sha512", ssh.Marshal(signature)})...)
+
+	encoded := base64.StdEncoding.EncodeToString(blob)
+	var armored strings.Builder
+	armored.WriteString("-----BEGIN SSH SIGNATURE-----\n")
+	for len(encoded) > 70 {
+		armored.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	armored.WriteString(encoded + "\n-----END SSH SIGNATURE-----\n")
+
+	return armored.String(), nil
+}
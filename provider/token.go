@@ -0,0 +1,186 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// The schemes tokens are sent with over HTTP(S).
+const (
+	authSchemeBasic  = "basic"
+	authSchemeBearer = "bearer"
+	authSchemeToken  = "token"
+)
+
+// tokenRefreshMargin is how long before its expiry a token is renewed, so it
+// does not expire during a git operation.
+const tokenRefreshMargin = 5 * time.Minute
+
+// tokenIssuer is implemented by auth methods that obtain credentials while
+// the provider runs, which have to be redacted like the configured ones.
+type tokenIssuer interface {
+	tokens() []string
+}
+
+// tokenSource returns a new token and the time it expires.
+type tokenSource func(ctx context.Context) (string, time.Time, error)
+
+// tokenAuth is an HTTP auth method for short-lived tokens. The token is
+// renewed when the next request is made after it (nearly) expired, so long
+// applies are not affected by the lifetime of a token. The token is sent as
+// the password of the username or, if the username is empty, in the
+// Authorization header with the scheme, which defaults to Bearer.
+type tokenAuth struct {
+	username string
+	scheme   string
+	source   tokenSource
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+	issued []string
+}
+
+// newTokenAuth returns an auth method with a first token from source.
+func newTokenAuth(ctx context.Context, username string, source tokenSource) (*tokenAuth, error) {
+	a := &tokenAuth{
+		username: username,
+		source:   source,
+	}
+	if err := a.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// refresh replaces the token with a new one from the source.
+func (a *tokenAuth) refresh(ctx context.Context) error {
+	token, expiry, err := a.source(ctx)
+	if err != nil {
+		return err
+	}
+
+	if token != a.token {
+		a.issued = append(a.issued, token)
+	}
+	a.token = token
+	a.expiry = expiry
+	return nil
+}
+
+// SetAuth implements http.AuthMethod. When the token cannot be renewed, the
+// current one is used until it expires, and the request fails with the error
+// afterwards.
+func (a *tokenAuth) SetAuth(r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.expiry.IsZero() && time.Until(a.expiry) < tokenRefreshMargin {
+		if err := a.refresh(r.Context()); err != nil {
+			if a.token == "" || !time.Now().Before(a.expiry) {
+				failAuth(r, fmt.Errorf("failed to renew token: %w", err))
+				return
+			}
+			tflog.Warn(r.Context(), "Failed to renew token, using the current one until it expires", map[string]interface{}{
+				"error":  err.Error(),
+				"expiry": a.expiry,
+			})
+		}
+	}
+
+	if a.username == "" {
+		scheme := a.scheme
+		if scheme == "" {
+			scheme = "Bearer"
+		}
+		r.Header.Set("Authorization", scheme+" "+a.token)
+		return
+	}
+	r.SetBasicAuth(a.username, a.token)
+}
+
+// Name implements transport.AuthMethod.
+func (a *tokenAuth) Name() string {
+	return "http-token-auth"
+}
+
+func (a *tokenAuth) String() string {
+	if a.username == "" {
+		scheme := a.scheme
+		if scheme == "" {
+			scheme = "Bearer"
+		}
+		return fmt.Sprintf("%s - %s *******", a.Name(), scheme)
+	}
+	return fmt.Sprintf("%s - %s:*******", a.Name(), a.username)
+}
+
+// authErrorHeader marks requests an auth method failed to set the credentials
+// of. go-git's auth methods cannot return errors, and the context of requests
+// is replaced after their credentials are set, so the error is passed in a
+// header to authErrorRoundTripper, which fails the request with it rather
+// than sending it.
+const authErrorHeader = "X-Terraform-Provider-Git-Auth-Error"
+
+var (
+	// authErrors are the errors of failAuth by the ID in authErrorHeader.
+	authErrors   sync.Map
+	authErrorIDs atomic.Uint64
+)
+
+// failAuth makes request r fail with err instead of being sent.
+func failAuth(r *http.Request, err error) {
+	id := strconv.FormatUint(authErrorIDs.Add(1), 10)
+	authErrors.Store(id, err)
+	r.Header.Set(authErrorHeader, id)
+}
+
+// authError returns the error request r was failed with by failAuth, if any.
+func authError(r *http.Request) error {
+	id := r.Header.Get(authErrorHeader)
+	if id == "" {
+		return nil
+	}
+
+	if err, ok := authErrors.LoadAndDelete(id); ok {
+		return err.(error)
+	}
+	return fmt.Errorf("failed to set credentials")
+}
+
+// headerAuth is an HTTP auth method sending a token in the Authorization
+// header with a scheme other than basic auth, e.g. `token` for GitHub.
+type headerAuth struct {
+	scheme string
+	token  string
+}
+
+// SetAuth implements http.AuthMethod.
+func (a *headerAuth) SetAuth(r *http.Request) {
+	r.Header.Set("Authorization", a.scheme+" "+a.token)
+}
+
+// Name implements transport.AuthMethod.
+func (a *headerAuth) Name() string {
+	return "http-header-auth"
+}
+
+func (a *headerAuth) String() string {
+	return fmt.Sprintf("%s - %s *******", a.Name(), a.scheme)
+}
+
+// tokens returns every token that was issued, so they can be redacted.
+func (a *tokenAuth) tokens() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]string(nil), a.issued...)
+}
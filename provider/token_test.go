@@ -0,0 +1,65 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenAuthRenewalError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization"))) //nolint:errcheck
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: &authErrorRoundTripper{next: http.DefaultTransport}}
+
+	tests := []struct {
+		name    string
+		expiry  time.Duration
+		wantErr bool
+	}{
+		{name: "token still valid", expiry: time.Minute},
+		{name: "token expired", expiry: -time.Minute, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := true
+			auth, err := newTokenAuth(context.Background(), "", func(context.Context) (string, time.Time, error) {
+				if first {
+					first = false
+					return "first", time.Now().Add(tt.expiry), nil
+				}
+				return "", time.Time{}, errors.New("token endpoint unavailable")
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			auth.SetAuth(req)
+
+			resp, err := client.Do(req)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "token endpoint unavailable") {
+					t.Errorf("Do() error = %v, want the renewal error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if req.Header.Get("Authorization") != "Bearer first" {
+				t.Errorf("Authorization = %q, want the current token", req.Header.Get("Authorization"))
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// httpOptions configure the client of git smart HTTP requests.
+type httpOptions struct {
+	headers    map[string]string
+	clientCert string
+	clientKey  string
+	caBundle   string
+	insecure   bool
+	proxyURL   *url.URL
+}
+
+// roundTripper returns the round tripper of HTTP(S) requests made with the
+// options: git requests, and the requests of auth methods for tokens. The
+// default transport of net/http is used when no option is set.
+func (o httpOptions) roundTripper() (http.RoundTripper, error) {
+	if o.clientCert == "" && o.clientKey == "" && o.caBundle == "" && !o.insecure && o.proxyURL == nil {
+		return http.DefaultTransport, nil
+	}
+
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.TLSClientConfig = &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: o.insecure, //nolint:gosec
+	}
+
+	if o.clientCert != "" || o.clientKey != "" {
+		if o.clientCert == "" || o.clientKey == "" {
+			return nil, fmt.Errorf("client_cert and client_key must be set together")
+		}
+
+		certificate, err := tls.X509KeyPair([]byte(o.clientCert), []byte(o.clientKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		base.TLSClientConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	if o.proxyURL != nil {
+		base.Proxy = http.ProxyURL(o.proxyURL)
+	}
+
+	if o.caBundle != "" {
+		pool, err := caBundlePool(o.caBundle)
+		if err != nil {
+			return nil, err
+		}
+		base.TLSClientConfig.RootCAs = pool
+	}
+
+	return base, nil
+}
+
+// gitTransportKey is the context key of the round tripper git requests are
+// sent with.
+type gitTransportKey struct{}
+
+// withGitTransport returns ctx with the round tripper of the provider
+// configuration meta, which git requests made with the context are sent with.
+func withGitTransport(ctx context.Context, meta interface{}) context.Context {
+	if cfg, ok := meta.(*providerConfig); ok && cfg.gitTransport != nil {
+		return context.WithValue(ctx, gitTransportKey{}, cfg.gitTransport)
+	}
+	return ctx
+}
+
+// installGitTransportOnce installs the HTTP(S) transports of go-git once per
+// process.
+var installGitTransportOnce sync.Once
+
+// installGitTransport replaces the HTTP(S) transports of go-git with ones
+// sending every request with the round tripper in its context, set by
+// withGitTransport, and failing the requests auth methods could not set the
+// credentials of. go-git's transports are shared by the whole process, so
+// they cannot hold the options of a provider configuration: every alias has
+// its own round tripper instead.
+func installGitTransport() {
+	installGitTransportOnce.Do(func() {
+		transport := githttp.NewClient(&http.Client{
+			Transport: &authErrorRoundTripper{next: contextRoundTripper{}},
+		})
+		client.InstallProtocol("http", transport)
+		client.InstallProtocol("https", transport)
+	})
+}
+
+// contextRoundTripper sends requests with the round tripper in their
+// context, or the default transport of net/http if there is none.
+type contextRoundTripper struct{}
+
+func (contextRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	if roundTripper, ok := r.Context().Value(gitTransportKey{}).(http.RoundTripper); ok {
+		return roundTripper.RoundTrip(r)
+	}
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// caBundlePool returns the system certificate pool with the certificates of a
+// CA bundle added. The bundle is either PEM content or the path of a PEM file.
+func caBundlePool(caBundle string) (*x509.CertPool, error) {
+	pem := []byte(caBundle)
+	if !strings.Contains(caBundle, "-----BEGIN") {
+		data, err := os.ReadFile(caBundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca_bundle: %w", err)
+		}
+		pem = data
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("ca_bundle contains no PEM encoded certificates")
+	}
+
+	return pool, nil
+}
+
+// headerRoundTripper adds headers to every request.
+type headerRoundTripper struct {
+	headers map[string]string
+	next    http.RoundTripper
+}
+
+func (t *headerRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	for key, value := range t.headers {
+		r.Header.Set(key, value)
+	}
+
+	return t.next.RoundTrip(r)
+}
+
+// authErrorRoundTripper fails requests with the error of the auth method, set
+// by failAuth.
+type authErrorRoundTripper struct {
+	next http.RoundTripper
+}
+
+func (t *authErrorRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	if err := authError(r); err != nil {
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		return nil, err
+	}
+
+	return t.next.RoundTrip(r)
+}
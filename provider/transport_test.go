@@ -0,0 +1,56 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func TestGitTransportPerConfiguration(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = append(received, r.Header.Get("X-Alias"))
+		mu.Unlock()
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	installGitTransport()
+	list := func(ctx context.Context) {
+		remote := gogit.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+			Name: "origin",
+			URLs: []string{server.URL + "/repo.git"},
+		})
+		// The server has no repositories
+		_, _ = remote.ListContext(ctx, &gogit.ListOptions{})
+	}
+
+	for _, alias := range []string{"a", "b"} {
+		cfg := &providerConfig{
+			gitTransport: &headerRoundTripper{
+				headers: map[string]string{"X-Alias": alias},
+				next:    http.DefaultTransport,
+			},
+		}
+		list(withGitTransport(context.Background(), cfg))
+	}
+	list(context.Background())
+
+	want := []string{"a", "b", ""}
+	if len(received) != len(want) {
+		t.Fatalf("received %q, want %q", received, want)
+	}
+	for i := range want {
+		if received[i] != want[i] {
+			t.Errorf("request %d has X-Alias %q, want %q", i, received[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// defaultPorts are the ports dropped when normalizing a URL.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ssh":   "22",
+}
+
+// normalizeURL returns a canonical form of a repository URL used to compare
+// URLs that refer to the same repository. The scheme, credentials, default
+// ports, letter case of the host and any trailing slash or .git suffix are not
+// significant, so https://github.com/org/repo, ssh://git@github.com/org/repo.git
+// and git@github.com:org/repo.git normalize to the same value.
+func normalizeURL(raw string) string {
+	u, err := url.Parse(expandSCPURL(raw))
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != defaultPorts[strings.ToLower(u.Scheme)] {
+		host = host + ":" + port
+	}
+
+	path := strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), ".git")
+
+	return host + path
+}
+
+// urlRewrite replaces the from prefix of a URL with to, mirroring git's
+// url.<base>.insteadOf configuration.
+type urlRewrite struct {
+	from string
+	to   string
+}
+
+// rewriteURL applies the URL rewrite with the longest matching prefix to raw,
+// and expands the result if it is an scp-like SSH URL.
+func (c *providerConfig) rewriteURL(raw string) string {
+	var match *urlRewrite
+	for i, rewrite := range c.urlRewrites {
+		if strings.HasPrefix(raw, rewrite.from) && (match == nil || len(rewrite.from) > len(match.from)) {
+			match = &c.urlRewrites[i]
+		}
+	}
+
+	if match != nil {
+		raw = match.to + strings.TrimPrefix(raw, match.from)
+	}
+
+	return expandSCPURL(raw)
+}
+
+// scpURLPattern matches scp-like SSH URLs, e.g. git@github.com:org/repo.git,
+// capturing the user, host and path.
+var scpURLPattern = regexp.MustCompile(`^(?:([^@/:]+)@)?([^@/:]+):(.+)$`)
+
+// expandSCPURL returns the ssh:// form of an scp-like SSH URL, e.g.
+// ssh://git@github.com/org/repo.git for git@github.com:org/repo.git. Other
+// URLs are returned unchanged.
+func expandSCPURL(raw string) string {
+	if strings.Contains(raw, "://") || isLocalPath(raw) {
+		return raw
+	}
+
+	match := scpURLPattern.FindStringSubmatch(raw)
+	if match == nil {
+		return raw
+	}
+
+	u := url.URL{
+		Scheme: "ssh",
+		Host:   match[2],
+		Path:   "/" + strings.TrimPrefix(match[3], "/"),
+	}
+	if match[1] != "" {
+		u.User = url.User(match[1])
+	}
+
+	return u.String()
+}
+
+// validateRepoURL ensures a value is a remote URL accepted by
+// validateRemoteURL, or the file URL or absolute path of a local repository
+// or bundle file.
+func validateRepoURL(i interface{}, k string) ([]string, []error) {
+	if v, ok := i.(string); ok && (isLocalPath(v) || strings.HasPrefix(v, "file://")) {
+		return nil, nil
+	}
+
+	return validateRemoteURL(i, k)
+}
+
+// validateRemoteURL ensures a value is an http, https or ssh URL, or an
+// scp-like SSH URL.
+func validateRemoteURL(i interface{}, k string) ([]string, []error) {
+	var warnings []string
+	if v, ok := i.(string); ok {
+		i = expandSCPURL(v)
+
+		// Unlike the provider configuration, resource and data source
+		// arguments are stored in the state
+		if u, err := url.Parse(v); err == nil && u.User != nil {
+			if _, hasPassword := u.User.Password(); hasPassword {
+				warnings = append(warnings, fmt.Sprintf("%s contains a password, which is stored in plain text in the state: configure credentials on the provider instead", k))
+			}
+		}
+	}
+
+	moreWarnings, errs := validation.IsURLWithScheme([]string{"http", "https", "ssh"})(i, k)
+	return append(warnings, moreWarnings...), errs
+}
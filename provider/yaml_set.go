@@ -0,0 +1,240 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/util"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"gopkg.in/yaml.v3"
+)
+
+// keyPathSegmentPattern matches a segment of a key path: a key followed by
+// sequence indexes, e.g. containers[0].
+var keyPathSegmentPattern = regexp.MustCompile(`^([^\[\]]*)((?:\[\d+\])*)$`)
+
+// yaml11Bools are the plain scalars read as booleans by YAML 1.1 parsers,
+// while they are strings in YAML 1.2.
+var yaml11Bools = map[string]bool{
+	"y": true, "yes": true, "n": true, "no": true, "on": true, "off": true,
+}
+
+// yamlString returns a scalar node of the string value, quoted when YAML
+// parsers would read it as another type.
+func yamlString(value string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	if yaml11Bools[strings.ToLower(value)] {
+		node.Style = yaml.DoubleQuotedStyle
+	}
+
+	return node
+}
+
+func resourceCommitYAMLSet() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"path": {
+				Description:  "The path of the YAML file to edit. The file must exist.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateRepoPath,
+			},
+			"key_path": {
+				Description:  "The path of the value to set in the first document of the file, with keys separated by dots and sequence indexes in brackets, e.g. `spec.template.spec.containers[0].image`. Missing keys are added.",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+			"value": {
+				Description: "The value to set. It is written as a string if the value it replaces is one, e.g. `\"1.10\"`, and as a plain scalar otherwise, e.g. `3`.",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+		},
+	}
+}
+
+// setYAMLValues sets the values of the yaml_set items in the files of
+// worktree. Only the text of the replaced values is changed when possible,
+// so the rest of the files, including comments and formatting, is untouched.
+func setYAMLValues(worktree *gogit.Worktree, items []interface{}) diag.Diagnostics {
+	for _, item := range items {
+		path := worktree.Filesystem.Join(item.(map[string]interface{})["path"].(string))
+		keyPath := item.(map[string]interface{})["key_path"].(string)
+		value := item.(map[string]interface{})["value"].(string)
+
+		content, err := util.ReadFile(worktree.Filesystem, path)
+		if err != nil {
+			return diag.Errorf("failed to read file %s: %s", path, err)
+		}
+
+		edited, err := setYAMLValue(content, keyPath, value)
+		if err != nil {
+			return diag.Errorf("failed to set %s in file %s: %s", keyPath, path, err)
+		}
+		if bytes.Equal(edited, content) {
+			continue
+		}
+
+		info, err := worktree.Filesystem.Lstat(path)
+		if err != nil {
+			return diag.Errorf("failed to read file %s: %s", path, err)
+		}
+		if err := util.WriteFile(worktree.Filesystem, path, edited, info.Mode().Perm()); err != nil {
+			return diag.Errorf("failed to write file %s: %s", path, err)
+		}
+	}
+
+	return nil
+}
+
+// setYAMLValue returns content with the value at keyPath of its first
+// document set to value.
+func setYAMLValue(content []byte, keyPath, value string) ([]byte, error) {
+	documents, err := decodeYAMLNodes(content)
+	if err != nil {
+		return nil, err
+	}
+	if len(documents) == 0 {
+		documents = append(documents, &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}})
+	}
+
+	node, created, err := lookupYAMLNode(documents[0].Content[0], keyPath)
+	if err != nil {
+		return nil, err
+	}
+	if node.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("%s is not a scalar", keyPath)
+	}
+
+	replacement := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	if node.Tag == "!!str" {
+		// Strings stay strings, quoted if they look like another type
+		replacement.Tag = node.Tag
+		replacement.Style = node.Style &^ yaml.TaggedStyle
+		if replacement.Style == 0 && yaml11Bools[strings.ToLower(value)] {
+			// YAML 1.1 parsers, e.g. the one of Kubernetes, read them as booleans
+			replacement.Style = yaml.DoubleQuotedStyle
+		}
+	}
+	if !created && node.Value == value && (node.Tag == "!!str") == (replacement.Tag == "!!str") {
+		return content, nil
+	}
+
+	// Replace the text of the value alone when it is on a single line
+	if !created {
+		encoded, err := yaml.Marshal(replacement)
+		if err != nil {
+			return nil, err
+		}
+		encoded = bytes.TrimSuffix(encoded, []byte("\n"))
+		if start, end, ok := scalarExtent(content, node); ok && !bytes.Contains(encoded, []byte("\n")) {
+			return append(append(append([]byte{}, content[:start]...), encoded...), content[end:]...), nil
+		}
+	}
+
+	replacement.HeadComment, replacement.LineComment, replacement.FootComment = node.HeadComment, node.LineComment, node.FootComment
+	*node = *replacement
+
+	return encodeYAMLNodes(documents)
+}
+
+// lookupYAMLNode returns the node at keyPath under root, adding the missing
+// keys of mappings. It reports whether the node was added.
+func lookupYAMLNode(root *yaml.Node, keyPath string) (*yaml.Node, bool, error) {
+	node, created := root, false
+	for _, segment := range strings.Split(keyPath, ".") {
+		match := keyPathSegmentPattern.FindStringSubmatch(segment)
+		if match == nil {
+			return nil, false, fmt.Errorf("invalid key path segment %q", segment)
+		}
+
+		if key := match[1]; key != "" {
+			if node.Kind != yaml.MappingNode {
+				return nil, false, fmt.Errorf("%s is not in a mapping", key)
+			}
+			var value *yaml.Node
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					value = node.Content[i+1]
+					break
+				}
+			}
+			if value == nil {
+				value = &yaml.Node{Kind: yaml.MappingNode}
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+				created = true
+			}
+			node = value
+		}
+
+		for _, index := range strings.Split(strings.Trim(match[2], "[]"), "][") {
+			if index == "" {
+				continue
+			}
+			i, _ := strconv.Atoi(index)
+			if node.Kind != yaml.SequenceNode || i >= len(node.Content) {
+				return nil, false, fmt.Errorf("index %d of %s is out of range", i, segment)
+			}
+			node = node.Content[i]
+		}
+	}
+
+	// Added keys end with a scalar rather than a mapping
+	if created {
+		*node = yaml.Node{Kind: yaml.ScalarNode}
+	}
+
+	return node, created, nil
+}
+
+// scalarExtent returns the offsets of the text of the single line scalar node
+// in content.
+func scalarExtent(content []byte, node *yaml.Node) (int, int, bool) {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	if node.Line < 1 || node.Line > len(lines) {
+		return 0, 0, false
+	}
+	start := node.Column - 1
+	for _, line := range lines[:node.Line-1] {
+		start += len(line)
+	}
+	if start < 0 || start >= len(content) {
+		return 0, 0, false
+	}
+
+	rest := content[start:]
+	switch node.Style {
+	case 0:
+		if strings.Contains(node.Value, "\n") || !bytes.HasPrefix(rest, []byte(node.Value)) {
+			return 0, 0, false
+		}
+		return start, start + len(node.Value), true
+	case yaml.DoubleQuotedStyle:
+		for i := 1; i < len(rest) && rest[0] == '"' && rest[i] != '\n'; i++ {
+			if rest[i] == '\\' {
+				i++
+			} else if rest[i] == '"' {
+				return start, start + i + 1, true
+			}
+		}
+	case yaml.SingleQuotedStyle:
+		for i := 1; i < len(rest) && rest[0] == '\'' && rest[i] != '\n'; i++ {
+			if rest[i] == '\'' {
+				if i+1 < len(rest) && rest[i+1] == '\'' {
+					i++
+					continue
+				}
+				return start, start + i + 1, true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"testing"
+)
+
+func TestSetYAMLValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		keyPath string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "plain scalar",
+			content: "# replicas\nreplicas: 1 # keep low\nname: app\n",
+			keyPath: "replicas",
+			value:   "3",
+			want:    "# replicas\nreplicas: 3 # keep low\nname: app\n",
+		},
+		{
+			name:    "quoted string",
+			content: "image:\n  tag: \"1.0\"\n",
+			keyPath: "image.tag",
+			value:   "1.10",
+			want:    "image:\n  tag: \"1.10\"\n",
+		},
+		{
+			name:    "string read as a boolean",
+			content: "enabled: maybe\n",
+			keyPath: "enabled",
+			value:   "yes",
+			want:    "enabled: \"yes\"\n",
+		},
+		{
+			name:    "sequence index",
+			content: "containers:\n  - name: app\n    image: app:1\n  - name: sidecar\n    image: sidecar:1\n",
+			keyPath: "containers[1].image",
+			value:   "sidecar:2",
+			want:    "containers:\n  - name: app\n    image: app:1\n  - name: sidecar\n    image: sidecar:2\n",
+		},
+		{
+			name:    "missing keys",
+			content: "name: app\n",
+			keyPath: "image.tag",
+			value:   "v1",
+			want:    "name: app\nimage:\n  tag: v1\n",
+		},
+		{
+			name:    "empty file",
+			content: "",
+			keyPath: "name",
+			value:   "app",
+			want:    "name: app\n",
+		},
+		{
+			name:    "unchanged",
+			content: "replicas:   3\n",
+			keyPath: "replicas",
+			value:   "3",
+			want:    "replicas:   3\n",
+		},
+		{
+			name:    "not a scalar",
+			content: "image:\n  tag: v1\n",
+			keyPath: "image",
+			value:   "v2",
+			wantErr: true,
+		},
+		{
+			name:    "index out of range",
+			content: "containers: []\n",
+			keyPath: "containers[0].image",
+			value:   "app:2",
+			wantErr: true,
+		},
+		{
+			name:    "invalid key path",
+			content: "name: app\n",
+			keyPath: "containers[x]",
+			value:   "app",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := setYAMLValue([]byte(tt.content), tt.keyPath, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setYAMLValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && string(got) != tt.want {
+				t.Errorf("setYAMLValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}